@@ -0,0 +1,186 @@
+package kick
+
+import "math"
+
+const (
+	FilterNone = iota
+	FilterLadder
+	FilterDiode
+	FilterSallenKey
+)
+
+const (
+	FilterEnvAmplitude = iota
+	FilterEnvPitch
+)
+
+// toneFilter is an analog-style low-pass filter, for the tone stage of the kick
+type toneFilter interface {
+	process(x, cutoff float64) float64
+	setResonance(resonance float64)
+}
+
+// newToneFilter returns the filter model selected in cfg.FilterModel, or nil if no filter is selected
+func (cfg *Settings) newToneFilter() toneFilter {
+	fourPole := cfg.FilterSlope != 12
+	drive := 1 + 2*cfg.FilterDrive
+	resonance := clampResonance(cfg.FilterResonance)
+	switch cfg.FilterModel {
+	case FilterLadder:
+		return &ladderFilter{sampleRate: float64(cfg.SampleRate), drive: drive, resonance: resonance, fourPole: fourPole}
+	case FilterDiode:
+		return &diodeFilter{sampleRate: float64(cfg.SampleRate), drive: drive, resonance: resonance, fourPole: fourPole}
+	case FilterSallenKey:
+		f := &sallenKeyFilter{sampleRate: float64(cfg.SampleRate), drive: drive, resonance: resonance}
+		if fourPole {
+			f.next = &sallenKeyFilter{sampleRate: float64(cfg.SampleRate), drive: drive, resonance: resonance}
+		}
+		return f
+	}
+	return nil
+}
+
+// filterCutoff returns the cutoff frequency, modulated by the given envelope value (0 to 1)
+func (cfg *Settings) filterCutoff(envelope float64) float64 {
+	return cfg.cutoff() * math.Pow(2, cfg.FilterEnvAmount*envelope)
+}
+
+// pitchEnvelope returns how far the given frequency is from EndFreq towards StartFreq, from 0 to 1
+func (cfg *Settings) pitchEnvelope(frequency float64) float64 {
+	startFreq := cfg.startFreq()
+	if startFreq == cfg.EndFreq {
+		return 0
+	}
+	return math.Max(0, math.Min(1, (frequency-cfg.EndFreq)/(startFreq-cfg.EndFreq)))
+}
+
+// saturate is the nonlinearity used inside the filter feedback loops. It has unity gain for small signals.
+func saturate(x, drive float64) float64 {
+	return math.Tanh(drive*x) / drive
+}
+
+// clampCutoff keeps the cutoff frequency in a range where the filters are stable
+func clampCutoff(cutoff, sampleRate float64) float64 {
+	return math.Max(10, math.Min(cutoff, sampleRate*0.45))
+}
+
+// clampResonance keeps the resonance in the 0 to 1 range
+func clampResonance(resonance float64) float64 {
+	return math.Max(0, math.Min(1, resonance))
+}
+
+// ladderFilter is a Moog-style 4-pole transistor ladder, with saturation in the feedback path and in each stage
+type ladderFilter struct {
+	sampleRate float64
+	drive      float64
+	resonance  float64
+	fourPole   bool
+	stage      [4]float64
+}
+
+func (f *ladderFilter) setResonance(resonance float64) {
+	f.resonance = clampResonance(resonance)
+}
+
+func (f *ladderFilter) process(x, cutoff float64) float64 {
+	g := 1 - math.Exp(-2*math.Pi*clampCutoff(cutoff, f.sampleRate)/f.sampleRate)
+	k := 4 * f.resonance
+	in := saturate(x-k*f.stage[3], f.drive)
+	f.stage[0] += g * (in - saturate(f.stage[0], f.drive))
+	f.stage[1] += g * (saturate(f.stage[0], f.drive) - saturate(f.stage[1], f.drive))
+	f.stage[2] += g * (saturate(f.stage[1], f.drive) - saturate(f.stage[2], f.drive))
+	f.stage[3] += g * (saturate(f.stage[2], f.drive) - saturate(f.stage[3], f.drive))
+	// Partly make up for the bass that is lost when the resonance is turned up
+	if f.fourPole {
+		return f.stage[3] * (1 + k/2)
+	}
+	return f.stage[1] * (1 + k/2)
+}
+
+// diodeFilter is a diode ladder, where each stage is loaded by the next one, as in the TB-303.
+// The coupled stages are integrated in several smaller steps, to keep the filter stable at high cutoff frequencies.
+type diodeFilter struct {
+	sampleRate float64
+	drive      float64
+	resonance  float64
+	fourPole   bool
+	stage      [4]float64
+}
+
+func (f *diodeFilter) setResonance(resonance float64) {
+	f.resonance = clampResonance(resonance)
+}
+
+func (f *diodeFilter) process(x, cutoff float64) float64 {
+	g := 2 * math.Pi * clampCutoff(cutoff, f.sampleRate) / f.sampleRate
+	steps := int(math.Ceil(g / 0.2))
+	g /= float64(steps)
+	k := 10 * f.resonance
+	s := &f.stage
+	for step := 0; step < steps; step++ {
+		in := saturate(x-k*s[3], f.drive)
+		d0 := g * (saturate(in-s[0], f.drive) - saturate(s[0]-s[1], f.drive))
+		d1 := g * (saturate(s[0]-s[1], f.drive) - saturate(s[1]-s[2], f.drive))
+		d2 := g * (saturate(s[1]-s[2], f.drive) - saturate(s[2]-s[3], f.drive))
+		d3 := g * saturate(s[2]-s[3], f.drive)
+		s[0] += d0
+		s[1] += d1
+		s[2] += d2
+		s[3] += d3
+	}
+	// Partly make up for the bass that is lost when the resonance is turned up
+	if f.fourPole {
+		return s[3] * (1 + k/2)
+	}
+	return s[1] * (1 + k/2)
+}
+
+// sallenKeyFilter is a 2-pole Sallen-Key low-pass filter, as in the Korg MS-20, built from
+// zero-delay feedback one-pole sections. A second filter can be chained for a 24 dB slope.
+type sallenKeyFilter struct {
+	sampleRate float64
+	drive      float64
+	resonance  float64
+	lpf1, lpf2 float64
+	hpf        float64
+	next       *sallenKeyFilter
+}
+
+func (f *sallenKeyFilter) setResonance(resonance float64) {
+	f.resonance = clampResonance(resonance)
+	if f.next != nil {
+		f.next.setResonance(resonance)
+	}
+}
+
+func (f *sallenKeyFilter) process(x, cutoff float64) float64 {
+	g := math.Tan(math.Pi * clampCutoff(cutoff, f.sampleRate) / f.sampleRate)
+	G := g / (1 + g)
+	k := 0.01 + 1.98*f.resonance // self-oscillation at 2
+
+	alpha := 1 / (1 - k*G + k*G*G)
+	beta2 := (k - k*G) / (1 + g)
+	beta3 := -1 / (1 + g)
+
+	// First low-pass section
+	v := (x - f.lpf1) * G
+	y1 := v + f.lpf1
+	f.lpf1 = y1 + v
+
+	// Feedback through the second low-pass and the high-pass section
+	u := alpha * (y1 + beta3*f.hpf + beta2*f.lpf2)
+	u = saturate(u, f.drive)
+
+	v = (u - f.lpf2) * G
+	y := k * (v + f.lpf2)
+	f.lpf2 = v + f.lpf2 + v
+
+	v = (y - f.hpf) * G
+	f.hpf = v + f.hpf + v
+
+	y /= k
+	if f.next != nil {
+		return f.next.process(y, cutoff)
+	}
+	return y
+}
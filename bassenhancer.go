@@ -0,0 +1,60 @@
+package kick
+
+import "math"
+
+// chebyshevWeights are the levels of the 2nd to the 5th harmonic, made by the bass enhancer
+var chebyshevWeights = []float64{0.4, 0.3, 0.2, 0.1}
+
+// bassEnhancerGain is the level of the harmonics at an amount of 1, which puts them about 10 dB below the fundamental
+const bassEnhancerGain = 0.65
+
+// chebyshev returns the Chebyshev polynomial of the first kind of order n at x. For a full-scale
+// sine wave as input, the result is a sine wave at n times the frequency.
+func chebyshev(n int, x float64) float64 {
+	t0, t1 := 1.0, x
+	if n == 0 {
+		return t0
+	}
+	for k := 1; k < n; k++ {
+		t0, t1 = t1, 2*x*t1-t0
+	}
+	return t1
+}
+
+// ApplyBassEnhancer adds upper harmonics of the sub band below the given frequency, so that the fundamental can be
+// perceived on small speakers that can not play it. The sub band is normalized by an envelope follower before the
+// Chebyshev waveshaping, so that the harmonics are controlled at any level, and the harmonics are band-limited
+// to between 1.5 times the given fundamental and 8 times the given frequency, so that the 2nd harmonic is kept
+// while no real low-frequency energy is added. A fundamental of 0 or below is taken to be half the frequency.
+func ApplyBassEnhancer(sampleRate int, amount, freq, fundamental float64, channels ...[]float64) {
+	if amount <= 0 || freq <= 0 {
+		return
+	}
+	if fundamental <= 0 {
+		fundamental = freq / 2
+	}
+	for _, ch := range channels {
+		lowPass := newCrossover(biquadLowPass, freq, sampleRate)
+		highPass := newCrossover(biquadHighPass, 1.5*fundamental, sampleRate)
+		bandLimit := newCrossover(biquadLowPass, 8*freq, sampleRate)
+		envelope := newEnvelopeFollower(0.001, 0.03, sampleRate)
+
+		for i, x := range ch {
+			sub := lowPass.process(x)
+			level := envelope.process(math.Abs(sub))
+			if level < 1e-6 {
+				// Keep the filters running, to avoid clicks when the sub band comes back
+				bandLimit.process(highPass.process(0))
+				continue
+			}
+			normalized := math.Max(-1, math.Min(1, sub/level))
+
+			var harmonics float64
+			for n, weight := range chebyshevWeights {
+				harmonics += weight * chebyshev(n+2, normalized)
+			}
+
+			ch[i] = x + bassEnhancerGain*amount*bandLimit.process(highPass.process(harmonics*level))
+		}
+	}
+}
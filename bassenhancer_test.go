@@ -0,0 +1,44 @@
+package kick
+
+import (
+	"math"
+	"testing"
+)
+
+// The harmonics should be about 10 dB below the fundamental, with the 2nd harmonic as the strongest one
+func TestBassEnhancerHarmonics(t *testing.T) {
+	const sampleRate = 48000
+	const fundamental = 45.0
+	input := make([]float64, sampleRate)
+	for i := range input {
+		input[i] = 0.8 * math.Sin(2*math.Pi*fundamental*float64(i)/sampleRate)
+	}
+	output := append([]float64(nil), input...)
+	ApplyBassEnhancer(sampleRate, 1, 120, fundamental, output)
+
+	// Skip the first half, where the filters and the envelope follower settle
+	added := make([]float64, sampleRate/2)
+	for i := range added {
+		added[i] = output[sampleRate/2+i] - input[sampleRate/2+i]
+	}
+	if level := toDB(rms(added) / rms(input[sampleRate/2:])); math.Abs(level+10) > 2 {
+		t.Errorf("the harmonics are at %.1f dB relative to the fundamental, want about -10 dB", level)
+	}
+
+	// The level of each harmonic, from a single DFT bin
+	harmonic := func(n int) float64 {
+		var re, im float64
+		for i, x := range added {
+			phase := 2 * math.Pi * float64(n) * fundamental * float64(i) / sampleRate
+			re += x * math.Cos(phase)
+			im += x * math.Sin(phase)
+		}
+		return math.Hypot(re, im)
+	}
+	second := harmonic(2)
+	for n := 3; n <= 5; n++ {
+		if level := harmonic(n); level > second {
+			t.Errorf("harmonic %d is stronger than the 2nd harmonic", n)
+		}
+	}
+}
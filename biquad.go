@@ -0,0 +1,108 @@
+package kick
+
+import "math"
+
+const (
+	biquadLowPass = iota
+	biquadHighPass
+	biquadBandPass
+	biquadNotch
+	biquadPeak
+	biquadLowShelf
+	biquadHighShelf
+)
+
+// biquad is a second order IIR filter, using the coefficients from the RBJ Audio EQ Cookbook
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+// newBiquad creates a filter of the given kind, for the given center/cutoff frequency and Q
+func newBiquad(kind int, freq, q float64, sampleRate int) *biquad {
+	return newGainBiquad(kind, freq, q, 0, sampleRate)
+}
+
+// newGainBiquad creates a filter of the given kind, with a gain in dB that is used by the peak and shelf filters
+func newGainBiquad(kind int, freq, q, gainDB float64, sampleRate int) *biquad {
+	nyquist := float64(sampleRate) / 2
+	if freq >= nyquist {
+		freq = nyquist * 0.99
+	}
+	if freq < 1 {
+		freq = 1
+	}
+	if q <= 0 {
+		q = math.Sqrt2 / 2
+	}
+
+	w0 := 2 * math.Pi * freq / float64(sampleRate)
+	cosW0 := math.Cos(w0)
+	alpha := math.Sin(w0) / (2 * q)
+	a := math.Pow(10, gainDB/40)
+
+	var b0, b1, b2, a0, a1, a2 float64
+	switch kind {
+	case biquadHighPass:
+		b0 = (1 + cosW0) / 2
+		b1 = -(1 + cosW0)
+		b2 = (1 + cosW0) / 2
+	case biquadBandPass:
+		b0 = alpha
+		b1 = 0
+		b2 = -alpha
+	case biquadNotch:
+		b0 = 1
+		b1 = -2 * cosW0
+		b2 = 1
+	case biquadPeak:
+		b0 = 1 + alpha*a
+		b1 = -2 * cosW0
+		b2 = 1 - alpha*a
+		a0 = 1 + alpha/a
+		a1 = -2 * cosW0
+		a2 = 1 - alpha/a
+	case biquadLowShelf:
+		sqrtA := 2 * math.Sqrt(a) * alpha
+		b0 = a * ((a + 1) - (a-1)*cosW0 + sqrtA)
+		b1 = 2 * a * ((a - 1) - (a+1)*cosW0)
+		b2 = a * ((a + 1) - (a-1)*cosW0 - sqrtA)
+		a0 = (a + 1) + (a-1)*cosW0 + sqrtA
+		a1 = -2 * ((a - 1) + (a+1)*cosW0)
+		a2 = (a + 1) + (a-1)*cosW0 - sqrtA
+	case biquadHighShelf:
+		sqrtA := 2 * math.Sqrt(a) * alpha
+		b0 = a * ((a + 1) + (a-1)*cosW0 + sqrtA)
+		b1 = -2 * a * ((a - 1) + (a+1)*cosW0)
+		b2 = a * ((a + 1) + (a-1)*cosW0 - sqrtA)
+		a0 = (a + 1) - (a-1)*cosW0 + sqrtA
+		a1 = 2 * ((a - 1) - (a+1)*cosW0)
+		a2 = (a + 1) - (a-1)*cosW0 - sqrtA
+	default: // biquadLowPass
+		b0 = (1 - cosW0) / 2
+		b1 = 1 - cosW0
+		b2 = (1 - cosW0) / 2
+	}
+	if a0 == 0 {
+		// The filters without gain share the same denominator
+		a0 = 1 + alpha
+		a1 = -2 * cosW0
+		a2 = 1 - alpha
+	}
+
+	return &biquad{
+		b0: b0 / a0,
+		b1: b1 / a0,
+		b2: b2 / a0,
+		a1: a1 / a0,
+		a2: a2 / a0,
+	}
+}
+
+// process filters a single sample
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
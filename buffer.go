@@ -0,0 +1,279 @@
+package kick
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/go-audio/audio"
+)
+
+// Buffer is audio with float samples, nominally from -1 to 1, with one slice of samples per channel.
+// Gain, Normalize and Reverse change the buffer in place and return it, so that calls can be chained,
+// while the other methods return new buffers.
+type Buffer struct {
+	SampleRate int
+	Channels   [][]float64
+}
+
+// NewBuffer returns a silent buffer with the given number of channels and samples per channel
+func NewBuffer(sampleRate, numChannels, length int) *Buffer {
+	channels := make([][]float64, numChannels)
+	for c := range channels {
+		channels[c] = make([]float64, length)
+	}
+	return &Buffer{SampleRate: sampleRate, Channels: channels}
+}
+
+// NumChannels returns the number of channels
+func (b *Buffer) NumChannels() int {
+	return len(b.Channels)
+}
+
+// Len returns the number of samples per channel
+func (b *Buffer) Len() int {
+	if len(b.Channels) == 0 {
+		return 0
+	}
+	return len(b.Channels[0])
+}
+
+// Duration returns the length of the buffer in seconds
+func (b *Buffer) Duration() float64 {
+	return float64(b.Len()) / float64(b.SampleRate)
+}
+
+// channel returns the given channel, or the last one if there are fewer channels, so that mono can be mixed into stereo
+func (b *Buffer) channel(c int) []float64 {
+	return b.Channels[min(c, len(b.Channels)-1)]
+}
+
+// Peak returns the largest absolute sample value
+func (b *Buffer) Peak() float64 {
+	var peak float64
+	for _, samples := range b.Channels {
+		for _, s := range samples {
+			peak = math.Max(peak, math.Abs(s))
+		}
+	}
+	return peak
+}
+
+// RMS returns the root mean square of all the samples
+func (b *Buffer) RMS() float64 {
+	var sum float64
+	var count int
+	for _, samples := range b.Channels {
+		sum += energy(samples)
+		count += len(samples)
+	}
+	if count == 0 {
+		return 0
+	}
+	return math.Sqrt(sum / float64(count))
+}
+
+// Gain changes the level by the given number of dB
+func (b *Buffer) Gain(gainDB float64) *Buffer {
+	applyGain(gainDB, b.Channels...)
+	return b
+}
+
+// Normalize brings the sample peak to the given level in dBFS. Silent buffers are left as they are.
+func (b *Buffer) Normalize(peakDB float64) *Buffer {
+	if peak := b.Peak(); peak > 0 {
+		b.Gain(peakDB - toDB(peak))
+	}
+	return b
+}
+
+// Reverse reverses the samples
+func (b *Buffer) Reverse() *Buffer {
+	for _, samples := range b.Channels {
+		for i, j := 0, len(samples)-1; i < j; i, j = i+1, j-1 {
+			samples[i], samples[j] = samples[j], samples[i]
+		}
+	}
+	return b
+}
+
+// Slice returns a copy of the part of the buffer between the given times, in seconds
+func (b *Buffer) Slice(start, end float64) *Buffer {
+	first := max(0, min(b.Len(), int(start*float64(b.SampleRate))))
+	last := max(first, min(b.Len(), int(end*float64(b.SampleRate))))
+	sliced := &Buffer{SampleRate: b.SampleRate, Channels: make([][]float64, len(b.Channels))}
+	for c, samples := range b.Channels {
+		sliced.Channels[c] = append([]float64(nil), samples[first:last]...)
+	}
+	return sliced
+}
+
+// Resample returns the buffer converted to the given sample rate
+func (b *Buffer) Resample(sampleRate int) *Buffer {
+	resampled := &Buffer{SampleRate: sampleRate, Channels: make([][]float64, len(b.Channels))}
+	for c, samples := range b.Channels {
+		resampled.Channels[c] = resample(samples, b.SampleRate, sampleRate)
+	}
+	return resampled
+}
+
+// combine returns a new buffer that fits all the given buffers, with the length given by the length function,
+// and checks that the sample rates match
+func (b *Buffer) combine(others []*Buffer, length func(int, int) int) (*Buffer, error) {
+	if b.NumChannels() == 0 {
+		return nil, errors.New("the buffer has no channels")
+	}
+	numChannels, total := b.NumChannels(), b.Len()
+	for i, other := range others {
+		if other.SampleRate != b.SampleRate {
+			return nil, fmt.Errorf("buffer %d has a sample rate of %d Hz, expected %d Hz", i+1, other.SampleRate, b.SampleRate)
+		}
+		if other.NumChannels() == 0 {
+			return nil, fmt.Errorf("buffer %d has no channels", i+1)
+		}
+		numChannels = max(numChannels, other.NumChannels())
+		total = length(total, other.Len())
+	}
+	return NewBuffer(b.SampleRate, numChannels, total), nil
+}
+
+// Concat returns the buffer followed by the given buffers, which must have the same sample rate.
+// Mono buffers are copied to both channels if any of the buffers is stereo.
+func (b *Buffer) Concat(others ...*Buffer) (*Buffer, error) {
+	result, err := b.combine(others, func(x, y int) int { return x + y })
+	if err != nil {
+		return nil, err
+	}
+	offset := 0
+	for _, buffer := range append([]*Buffer{b}, others...) {
+		for c, samples := range result.Channels {
+			copy(samples[offset:], buffer.channel(c))
+		}
+		offset += buffer.Len()
+	}
+	return result, nil
+}
+
+// Mix returns the sum of the buffer and the given buffers, which must have the same sample rate.
+// The result is as long as the longest buffer, and mono buffers are mixed into both channels
+// if any of the buffers is stereo.
+func (b *Buffer) Mix(others ...*Buffer) (*Buffer, error) {
+	result, err := b.combine(others, func(x, y int) int { return max(x, y) })
+	if err != nil {
+		return nil, err
+	}
+	for _, buffer := range append([]*Buffer{b}, others...) {
+		for c, samples := range result.Channels {
+			for i, s := range buffer.channel(c) {
+				samples[i] += s
+			}
+		}
+	}
+	return result, nil
+}
+
+// WriteWAV writes the buffer as a PCM WAV file with the given bit depth
+func (b *Buffer) WriteWAV(w io.WriteSeeker, bitDepth int) error {
+	return WriteWAV(w, b.Channels, b.SampleRate, bitDepth)
+}
+
+// IntBuffer converts the buffer to an interleaved go-audio IntBuffer with the given bit depth.
+// As in WAV files, 8-bit samples are unsigned, from 0 to 255.
+func (b *Buffer) IntBuffer(bitDepth int) *audio.IntBuffer {
+	data, _ := quantize(interleave(b.Channels...), bitDepth)
+	if bitDepth == 8 {
+		for i := range data {
+			data[i] += 128
+		}
+	}
+	return &audio.IntBuffer{
+		Data:           data,
+		Format:         &audio.Format{SampleRate: b.SampleRate, NumChannels: b.NumChannels()},
+		SourceBitDepth: bitDepth,
+	}
+}
+
+// FloatBuffer converts the buffer to an interleaved go-audio FloatBuffer
+func (b *Buffer) FloatBuffer() *audio.FloatBuffer {
+	return &audio.FloatBuffer{
+		Data:   append([]float64(nil), interleave(b.Channels...)...),
+		Format: &audio.Format{SampleRate: b.SampleRate, NumChannels: b.NumChannels()},
+	}
+}
+
+// deinterleave splits interleaved samples into channels
+func deinterleave(data []float64, numChannels int) [][]float64 {
+	numChannels = max(1, numChannels)
+	channels := make([][]float64, numChannels)
+	for c := range channels {
+		channels[c] = make([]float64, len(data)/numChannels)
+		for i := range channels[c] {
+			channels[c][i] = data[i*numChannels+c]
+		}
+	}
+	return channels
+}
+
+// NewBufferFromInt converts an interleaved go-audio IntBuffer to a Buffer, using its SourceBitDepth,
+// or 16 bits if it is not set. As in WAV files, 8-bit samples are expected to be unsigned.
+func NewBufferFromInt(buf *audio.IntBuffer) (*Buffer, error) {
+	if buf == nil || buf.Format == nil {
+		return nil, errors.New("the buffer has no format")
+	}
+	bitDepth := buf.SourceBitDepth
+	if bitDepth == 0 {
+		bitDepth = 16
+	}
+	data := buf.Data
+	if bitDepth == 8 {
+		// 8-bit samples are unsigned
+		data = make([]int, len(buf.Data))
+		for i, sample := range buf.Data {
+			data[i] = sample - 128
+		}
+	}
+	return &Buffer{
+		SampleRate: buf.Format.SampleRate,
+		Channels:   deinterleave(toFloats(data, bitDepth), buf.Format.NumChannels),
+	}, nil
+}
+
+// NewBufferFromFloat converts an interleaved go-audio FloatBuffer to a Buffer
+func NewBufferFromFloat(buf *audio.FloatBuffer) (*Buffer, error) {
+	if buf == nil || buf.Format == nil {
+		return nil, errors.New("the buffer has no format")
+	}
+	return &Buffer{
+		SampleRate: buf.Format.SampleRate,
+		Channels:   deinterleave(buf.Data, buf.Format.NumChannels),
+	}, nil
+}
+
+// ReadBuffer decodes a PCM WAV file into a Buffer
+func ReadBuffer(r io.ReadSeeker) (*Buffer, error) {
+	channels, sampleRate, _, err := ReadWAV(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Buffer{SampleRate: sampleRate, Channels: channels}, nil
+}
+
+// Render renders the kick through the effects chain and the output stage, and returns it as a Buffer,
+// without converting it to the configured bit depth
+func (cfg *Settings) Render() (*Buffer, error) {
+	channels, err := cfg.renderChannels()
+	if err != nil {
+		return nil, err
+	}
+	return &Buffer{SampleRate: cfg.SampleRate, Channels: cfg.finishChannels(channels...)}, nil
+}
+
+// Render mixes the layers and sends them through the output stage of Master, and returns the result as a Buffer
+func (l *Layered) Render() (*Buffer, error) {
+	channels, err := l.Mix()
+	if err != nil {
+		return nil, err
+	}
+	return &Buffer{SampleRate: l.Master.SampleRate, Channels: l.Master.finishChannels(channels...)}, nil
+}
@@ -0,0 +1,85 @@
+package kick
+
+import (
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBufferIntRoundTrip(t *testing.T) {
+	b := &Buffer{SampleRate: 8000, Channels: [][]float64{{0, 0.5, -0.5, 0.25}, {0, -0.25, 0.5, -1}}}
+	for _, bitDepth := range []int{8, 16, 24} {
+		back, err := NewBufferFromInt(b.IntBuffer(bitDepth))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if back.NumChannels() != 2 || back.Len() != 4 {
+			t.Fatalf("%d-bit: got %d channels of %d samples", bitDepth, back.NumChannels(), back.Len())
+		}
+		for c := range b.Channels {
+			for i, want := range b.Channels[c] {
+				if got := back.Channels[c][i]; math.Abs(got-want) > 1.0/128 {
+					t.Errorf("%d-bit: sample %d of channel %d is %f, want %f", bitDepth, i, c, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestBufferErrors(t *testing.T) {
+	full := NewBuffer(8000, 1, 10)
+	if _, err := (&Buffer{SampleRate: 8000}).Mix(full); err == nil {
+		t.Error("mixing into an empty buffer should return an error")
+	}
+	if _, err := (&Buffer{SampleRate: 8000}).Concat(full); err == nil {
+		t.Error("concatenating to an empty buffer should return an error")
+	}
+	if _, err := full.Mix(NewBuffer(16000, 1, 10)); err == nil {
+		t.Error("mixing buffers with different sample rates should return an error")
+	}
+	if _, err := NewBufferFromInt(nil); err == nil {
+		t.Error("converting a nil IntBuffer should return an error")
+	}
+	if _, err := NewBufferFromFloat(nil); err == nil {
+		t.Error("converting a nil FloatBuffer should return an error")
+	}
+}
+
+// An 8-bit render should read back with the same sign as it was generated
+func TestGenerateKick8Bit(t *testing.T) {
+	cfg, err := New909(48000, 0.2, 8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(filepath.Join(t.TempDir(), "kick.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	cfg.Output = file
+	if err := cfg.GenerateKick(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	channels, _, bitDepth, err := ReadWAV(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bitDepth != 8 {
+		t.Fatalf("got a bit depth of %d, want 8", bitDepth)
+	}
+	buffer, err := cfg.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := buffer.Channels[0]
+	for i, sample := range channels[0] {
+		if math.Abs(sample-want[i]) > 2.0/256 {
+			t.Fatalf("sample %d reads back as %.3f, want %.3f", i, sample, want[i])
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package kick
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Processor is a step in the effects chain of a kick. It processes the channels in place, or returns new
+// channels if the number of channels or the length changes. Settings and Stats can be read from cfg.
+type Processor interface {
+	Process(cfg *Settings, channels [][]float64) ([][]float64, error)
+}
+
+// ProcessorFunc turns a function into a Processor
+type ProcessorFunc func(cfg *Settings, channels [][]float64) ([][]float64, error)
+
+// Process calls the function
+func (f ProcessorFunc) Process(cfg *Settings, channels [][]float64) ([][]float64, error) {
+	return f(cfg, channels)
+}
+
+// defaultChain is the order of the effects chain when Settings.Chain is empty
+var defaultChain = []string{"saturator", "multiband", "eq", "bassenhancer", "compressor", "transient", "convolution", "reverb", "lofi", "cleanup", "fade"}
+
+// DefaultChain returns a copy of the order of the effects chain that is used when Settings.Chain is empty.
+// The noise layer and the click are not processors, since they are mixed in with the oscillators,
+// before the tone filter and the chain.
+func DefaultChain() []string {
+	return append([]string(nil), defaultChain...)
+}
+
+// processorsMutex guards processors, so that processors can be registered while kicks are rendered
+var processorsMutex sync.RWMutex
+
+// processors holds the processors that can be used in a chain, by name
+var processors = map[string]Processor{
+	"saturator": ProcessorFunc(func(cfg *Settings, channels [][]float64) ([][]float64, error) {
+		cfg.applyStereoNonlinear(channels, func(samples []float64) {
+			applySaturator(samples, cfg.SaturatorAmount)
+		})
+		return channels, nil
+	}),
+	"multiband": ProcessorFunc(func(cfg *Settings, channels [][]float64) ([][]float64, error) {
+		for _, samples := range channels {
+			applyMultiBandFiltering(samples, cfg.FilterBands, cfg.SampleRate)
+		}
+		return channels, nil
+	}),
+	"eq": ProcessorFunc(func(cfg *Settings, channels [][]float64) ([][]float64, error) {
+		ApplyEQ(cfg.SampleRate, cfg.EQ, channels...)
+		return channels, nil
+	}),
+	"bassenhancer": ProcessorFunc(func(cfg *Settings, channels [][]float64) ([][]float64, error) {
+		ApplyBassEnhancer(cfg.SampleRate, cfg.BassEnhance, cfg.BassEnhanceFreq, cfg.EndFreq, channels...)
+		return channels, nil
+	}),
+	"compressor": ProcessorFunc(func(cfg *Settings, channels [][]float64) ([][]float64, error) {
+		cfg.Stats.MaxGainReduction, cfg.Stats.AverageGainReduction = cfg.applyCompressor(channels...)
+		return channels, nil
+	}),
+	"transient": ProcessorFunc(func(cfg *Settings, channels [][]float64) ([][]float64, error) {
+		ApplyTransientShaper(cfg.SampleRate, cfg.TransientAttack, cfg.TransientSustain, channels...)
+		return channels, nil
+	}),
+	"convolution": ProcessorFunc(func(cfg *Settings, channels [][]float64) ([][]float64, error) {
+		return cfg.applyConvolution(channels)
+	}),
+	"reverb": ProcessorFunc(func(cfg *Settings, channels [][]float64) ([][]float64, error) {
+		return cfg.applyReverb(channels), nil
+	}),
+	// A start phase is not faded in, to keep the punch
+	"fade": ProcessorFunc(func(cfg *Settings, channels [][]float64) ([][]float64, error) {
+		if cfg.FadeDuration > 0 {
+			for _, samples := range channels {
+				applyFadeInOut(samples, cfg.SampleRate, cfg.FadeDuration, !cfg.hasStartPhase())
+			}
+		}
+		return channels, nil
+	}),
+	"lofi": ProcessorFunc(func(cfg *Settings, channels [][]float64) ([][]float64, error) {
+		ApplyLoFi(cfg.SampleRate, cfg.LoFi, channels...)
+		return channels, nil
+	}),
+	"cleanup": ProcessorFunc(func(cfg *Settings, channels [][]float64) ([][]float64, error) {
+		return channels, cfg.applyCleanup(channels...)
+	}),
+}
+
+// RegisterProcessor makes a processor available by name, so that it can be used in Settings.Chain.
+// It replaces any processor with the same name.
+func RegisterProcessor(name string, p Processor) {
+	processorsMutex.Lock()
+	defer processorsMutex.Unlock()
+	processors[strings.ToLower(name)] = p
+}
+
+// LookupProcessor returns the processor with the given name
+func LookupProcessor(name string) (Processor, error) {
+	processorsMutex.RLock()
+	p, ok := processors[strings.ToLower(name)]
+	processorsMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown processor %q, choose from: %s", name, strings.Join(ProcessorNames(), ", "))
+	}
+	return p, nil
+}
+
+// ProcessorNames returns the names of the registered processors, sorted
+func ProcessorNames() []string {
+	processorsMutex.RLock()
+	var names []string
+	for name := range processors {
+		names = append(names, name)
+	}
+	processorsMutex.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// ParseChain parses a comma-separated list of processor names, like "saturator,eq,compressor"
+func ParseChain(input string) ([]string, error) {
+	var chain []string
+	for _, name := range strings.Split(input, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, err := LookupProcessor(name); err != nil {
+			return nil, err
+		}
+		chain = append(chain, name)
+	}
+	return chain, nil
+}
+
+// chain returns the names of the processors to run, which is the default chain unless Chain is set
+func (cfg *Settings) chain() []string {
+	if len(cfg.Chain) > 0 {
+		return cfg.Chain
+	}
+	return defaultChain
+}
+
+// applyChain runs the channels through the processors of the chain, in order. The stats of the compressor
+// are reset first, so that they are 0 when the compressor is not in the chain.
+func (cfg *Settings) applyChain(channels [][]float64) ([][]float64, error) {
+	cfg.Stats.MaxGainReduction, cfg.Stats.AverageGainReduction = 0, 0
+	for _, name := range cfg.chain() {
+		p, err := LookupProcessor(name)
+		if err != nil {
+			return nil, err
+		}
+		if channels, err = p.Process(cfg, channels); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return channels, nil
+}
@@ -0,0 +1,33 @@
+package kick
+
+import "math"
+
+// clickLayer is a short, decaying sine burst on top of the kick, like the sound of a beater
+type clickLayer struct {
+	level      float64
+	freq       float64
+	decay      float64
+	sampleRate int
+}
+
+// newClickLayer sets up the click layer, with the level scaled by the velocity
+func (cfg *Settings) newClickLayer() *clickLayer {
+	return &clickLayer{
+		level:      cfg.ClickLevel * cfg.velocityScale(cfg.VelocityClick),
+		freq:       cfg.ClickFreq,
+		decay:      cfg.ClickDecay,
+		sampleRate: cfg.SampleRate,
+	}
+}
+
+// sample returns the click at the given sample index
+func (c *clickLayer) sample(i int) float64 {
+	if c.level == 0 || c.decay <= 0 {
+		return 0
+	}
+	t := float64(i) / float64(c.sampleRate)
+	if t > 10*c.decay {
+		return 0
+	}
+	return c.level * math.Sin(2*math.Pi*c.freq*t) * math.Exp(-t/c.decay)
+}
@@ -3,7 +3,10 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -23,6 +26,13 @@ func main() {
 	kickExperimental := flag.Bool("experimental", false, "Generate a kick.wav with experimental-style characteristics")
 	noiseType := flag.String("noise", "none", "Type of noise to mix in (none, white, pink, brown)")
 	noiseAmount := flag.Float64("noiseamount", 0.0, "Amount of noise to mix in (0.0 to 1.0)")
+	noiseAttack := flag.Float64("noiseattack", 0.0005, "Attack time of the noise layer in seconds")
+	noiseDecay := flag.Float64("noisedecay", 0.05, "Decay time of the noise layer in seconds (0 for constant noise)")
+	noiseDelay := flag.Float64("noisedelay", 0.0, "Start offset of the noise layer in seconds")
+	noiseFilter := flag.String("noisefilter", "highpass", "Filter for the noise layer (none, highpass, bandpass)")
+	noiseFilterFreq := flag.Float64("noisefreq", 2000.0, "Noise layer filter frequency (Hz)")
+	noiseFilterQ := flag.Float64("noiseq", 0.707, "Noise layer filter Q")
+	noiseSeed := flag.Int64("noiseseed", 0, "Seed for the noise, the same seed gives the same noise")
 	length := flag.Float64("length", 1000, "Length of the kick drum sample in milliseconds")
 	quality := flag.Int("quality", 96, "Sample rate in kHz (48 or 96)")
 	bitDepth := flag.Int("bitdepth", 16, "Bit depth of the audio (16 or 24)")
@@ -33,12 +43,91 @@ func main() {
 	release := flag.Float64("release", 0.15, "Release time in seconds")
 	sweep := flag.Float64("sweep", 0.8, "Pitch sweep rate")
 	filterCutoff := flag.Float64("filter", 5000.0, "Low-pass filter cutoff frequency (Hz)")
+	filterResonance := flag.Float64("resonance", 0.2, "Low-pass filter resonance (0.0 to 1.0)")
+	filterModel := flag.String("filtermodel", "none", "Analog filter model for the tone stage (none, ladder, diode, sallenkey)")
+	filterDrive := flag.Float64("filterdrive", 0.0, "Drive inside the filter feedback loop (0.0 to 1.0)")
+	filterSlope := flag.Int("filterslope", 24, "Filter slope in dB per octave (12 or 24)")
+	filterEnvAmount := flag.Float64("filterenv", 0.0, "Cutoff envelope amount, in octaves")
+	filterEnvSource := flag.String("filterenvsource", "amp", "Envelope that drives the cutoff (amp, pitch)")
 	pitchDecay := flag.Float64("pitchdecay", 0.2, "Pitch envelope decay time")
 	drive := flag.Float64("drive", 0.1, "Amount of distortion/drive")
 	numOscillators := flag.Int("numoscillators", 1, "Number of oscillators for layering")
 	oscillatorLevels := flag.String("oscillatorlevels", "1.0", "Comma-separated levels for each oscillator")
+	startPhase := flag.Float64("phase", 0.0, "Start phase of the oscillators in degrees (0 to 360), like 90 for an instant-on punch")
+	oscillatorPhases := flag.String("oscillatorphases", "", "Comma-separated start phases for each oscillator in degrees, relative to the start phase")
+	autoPhase := flag.String("autophase", "none", "Pick the start phase automatically (none, punch, noclick)")
+	clickLevel := flag.Float64("click", 0.0, "Level of the beater click layer (0.0 to 1.0)")
+	clickFreq := flag.Float64("clickfreq", 3000.0, "Frequency of the beater click (Hz)")
+	clickDecay := flag.Float64("clickdecay", 4.0, "Decay time of the beater click, in milliseconds")
+	velocity := flag.Float64("velocity", 1.0, "Velocity of the hit, from 0.0 to 1.0 (full), or as a MIDI velocity from 2 to 127")
+	velocityAmp := flag.Float64("velamp", 1.0, "How much the velocity changes the level (0.0 to 1.0)")
+	velocityDrive := flag.Float64("veldrive", 0.5, "How much the velocity changes the drive (0.0 to 1.0)")
+	velocityPitch := flag.Float64("velpitch", 0.5, "How much the velocity changes the depth of the pitch envelope (0.0 to 1.0)")
+	velocityClick := flag.Float64("velclick", 1.0, "How much the velocity changes the click level (0.0 to 1.0)")
+	velocityCutoff := flag.Float64("velcutoff", 2.0, "How many octaves the filter cutoff is lowered at the lowest velocity")
 	saturatorAmount := flag.Float64("saturator", 0.3, "Amount of saturation to apply")
 	filterBands := flag.String("filterbands", "200,1000,3000", "Comma-separated multi-band filter cutoff frequencies")
+	compThreshold := flag.Float64("compthreshold", -12.0, "Compressor threshold in dBFS")
+	compRatio := flag.Float64("compratio", 1.0, "Compressor ratio (1.0 disables the compressor)")
+	compAttack := flag.Float64("compattack", 0.01, "Compressor attack time in seconds")
+	compRelease := flag.Float64("comprelease", 0.1, "Compressor release time in seconds")
+	compKnee := flag.Float64("compknee", 6.0, "Compressor knee width in dB")
+	compMakeup := flag.Float64("compmakeup", 0.0, "Compressor makeup gain in dB")
+	compHighPass := flag.Float64("comphighpass", 0.0, "Compressor sidechain high-pass frequency (Hz, 0 to disable)")
+	transientAttack := flag.Float64("transientattack", 0.0, "Transient shaper attack gain in dB")
+	transientSustain := flag.Float64("transientsustain", 0.0, "Transient shaper sustain gain in dB")
+	normalize := flag.Bool("normalize", true, "Normalize the output to the target peak or loudness")
+	targetPeak := flag.Float64("peak", -1.0, "Target true peak of the output in dBFS, also used as the limiter ceiling")
+	targetLoudness := flag.Float64("loudness", 0.0, "Target integrated loudness in LUFS, instead of the target peak (0 to disable)")
+	limiter := flag.Bool("limiter", true, "Limit the true peak of the output to the target peak")
+	stereo := flag.Bool("stereo", false, "Generate a stereo kick, with a mono sub-band")
+	stereoWidth := flag.Float64("width", 0.5, "Stereo width above the crossover frequency (0.0 to 1.0)")
+	stereoCrossover := flag.Float64("crossover", 150.0, "Frequency below which the kick stays mono (Hz)")
+	stereoDetune := flag.Float64("detune", 10.0, "Detune between the left and the right channel, in cents")
+	stereoDelay := flag.Float64("stereodelay", 0.3, "Micro-delay of the right channel above the crossover, in milliseconds")
+	reverbMix := flag.Float64("reverb", 0.0, "Reverb wet/dry mix (0.0 to 1.0, 0.0 disables the reverb)")
+	reverbSize := flag.Float64("reverbsize", 0.5, "Reverb room size (0.0 to 1.0)")
+	reverbDamping := flag.Float64("reverbdamping", 0.5, "Reverb high frequency damping (0.0 to 1.0)")
+	reverbPreDelay := flag.Float64("predelay", 10.0, "Reverb pre-delay in milliseconds")
+	reverbLowCut := flag.Float64("reverblowcut", 200.0, "High-pass frequency of the reverb, so that the sub stays dry (Hz)")
+	reverbTail := flag.Float64("reverbtail", 0.0, "Length of the reverb tail after the kick, in milliseconds (0 for automatic)")
+	irFile := flag.String("ir", "", "Impulse response WAV file to convolve the kick with")
+	irMix := flag.Float64("irmix", 1.0, "Convolution wet/dry mix (0.0 to 1.0)")
+	irLength := flag.Float64("irlength", 0.0, "Maximum length of the impulse response in milliseconds (0 for the full length)")
+	inputFile := flag.String("in", "", "Process an existing WAV file with the transient shaper, the impulse response and the lo-fi settings, instead of generating a kick")
+	lofiSampler := flag.String("lofi", "", "Emulate the signal path of a vintage sampler (sp1200, mpc60, s950, 8bit)")
+	lofiBits := flag.Int("bits", 0, "Reduce the bit depth of the sound to this many bits (0 to disable)")
+	lofiRate := flag.Float64("lofirate", 0.0, "Reduce the sample rate of the sound with sample-and-hold, in Hz (0 to disable)")
+	lofiAntiAlias := flag.Bool("antialias", false, "Low-pass filter the sound before reducing the sample rate")
+	dcBlock := flag.Bool("dcblock", true, "Remove DC offset at the end of the chain")
+	subsonicFreq := flag.Float64("subsonic", 20.0, "Sub-sonic high-pass frequency at the end of the chain (Hz, 0 to disable)")
+	subsonicSlope := flag.Int("subsonicslope", 24, "Sub-sonic high-pass slope in dB per octave (12, 24, 36 or 48)")
+	trim := flag.Float64("trim", 0.0, "Trim trailing audio below this level in dBFS, like -60 (0 to disable)")
+	zeroCrossings := flag.Bool("zerocrossings", false, "Move the end of the sample to a zero crossing")
+	antiClick := flag.Float64("anticlick", 1.0, "Length of the fade-out at the trimmed end, in milliseconds")
+	eq := flag.String("eq", "", "Comma-separated EQ bands, like \"hp:30:24,peak:60:3:1.4,peak:300:-4:2,hs:6000:2\" (types: peak, ls, hs, notch, hp, lp)")
+	modulation := flag.String("mod", "", "Comma-separated modulation routes, like \"lfo:sine:1/16>cutoff:1,env:0:0.05>drive:0.5,random:1/32>pan:0.3\"")
+	tempo := flag.Float64("tempo", 120.0, "Tempo for LFOs and random sources that are synced to note lengths (BPM)")
+	chain := flag.String("chain", "", "Comma-separated order of the effects chain (default \""+strings.Join(kick.DefaultChain(), ",")+"\")")
+	bassEnhance := flag.Float64("bassenhance", 0.0, "Amount of harmonics to add for small speakers (0.0 to 1.0)")
+	bassEnhanceFreq := flag.Float64("bassenhancefreq", 120.0, "Frequency below which the bass enhancer makes harmonics (Hz)")
+	note := flag.String("note", "", "Tune the tail of the kick to a note, like F1 or A#0")
+	key := flag.String("key", "", "Tune the tail of the kick to the nearest low note in a key, like \"F minor\"")
+	a4 := flag.Float64("a4", 440.0, "Reference frequency of A4 when tuning to a note (Hz)")
+	cents := flag.Float64("cents", 0.0, "Offset in cents when tuning to a note")
+	interval := flag.Float64("interval", 0.0, "Start frequency in semitones above the tuned note (0 keeps the ratio of the preset)")
+	sclFile := flag.String("scl", "", "Scala .scl scale file to tune notes with, instead of 12-TET")
+	kbmFile := flag.String("kbm", "", "Scala .kbm keyboard mapping file for the scale")
+	pitchTolerance := flag.Float64("pitchtolerance", 25.0, "Warn when the detected pitch of the tail is this many cents off the tuned note")
+	variants := flag.Int("variants", 0, "Render this many round-robin variants, named like kick_rr01.wav (0 to disable)")
+	variantSeed := flag.Int64("rrseed", 1, "Random seed for the round-robin variants")
+	variantPitch := flag.Float64("rrpitch", kick.DefaultVariation.Pitch, "Largest pitch deviation of the round-robin variants, in cents")
+	variantDecay := flag.Float64("rrdecay", kick.DefaultVariation.Decay*100, "Largest decay deviation of the round-robin variants, in percent")
+	variantClick := flag.Float64("rrclick", kick.DefaultVariation.Click*100, "Largest click level deviation of the round-robin variants, in percent")
+	variantNoise := flag.Float64("rrnoise", kick.DefaultVariation.Noise*100, "Largest noise amount deviation of the round-robin variants, in percent")
+	variantPhase := flag.Float64("rrphase", kick.DefaultVariation.Phase, "Largest start phase deviation of the round-robin variants, in degrees")
+	stems := flag.Bool("stems", false, "Also write the oscillators, the noise and the click as stems, like kick_body.wav")
+	chromatic := flag.String("chromatic", "", "Render one file per note in a range, like C1-B1, named after the output file")
 	outputFile := flag.String("o", "kick.wav", "Output file path")
 	showVersion := flag.Bool("version", false, "Show the current version")
 	showHelp := flag.Bool("help", false, "Display this help")
@@ -55,6 +144,48 @@ func main() {
 		return
 	}
 
+	// Set up the lo-fi signal path, from a sampler emulation and/or the individual settings
+	var lofi kick.LoFi
+	if *lofiSampler != "" {
+		var err error
+		lofi, err = kick.LoFiSampler(*lofiSampler)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if *lofiBits > 0 {
+		lofi.Bits = *lofiBits
+	}
+	if *lofiRate > 0 {
+		lofi.Rate = *lofiRate
+	}
+	if *lofiAntiAlias {
+		lofi.AntiAlias = true
+	}
+
+	// Process an existing WAV file instead of generating a kick
+	if *inputFile != "" {
+		err := kick.ProcessWAVFile(*inputFile, *outputFile, func(channels [][]float64, sampleRate int) ([][]float64, error) {
+			kick.ApplyTransientShaper(sampleRate, *transientAttack, *transientSustain, channels...)
+			if *irFile != "" {
+				ir, err := kick.LoadImpulseResponse(*irFile, sampleRate, *irLength/1000.0)
+				if err != nil {
+					return nil, err
+				}
+				channels = kick.Convolve(channels, ir, *irMix)
+			}
+			kick.ApplyLoFi(sampleRate, lofi, channels...)
+			return channels, nil
+		})
+		if err != nil {
+			fmt.Println("Failed to process WAV file:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Processed", *inputFile, "and wrote the result to", *outputFile)
+		return
+	}
+
 	// Set sample rate based on the quality flag
 	var sampleRate int
 	switch *quality {
@@ -71,40 +202,36 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Open the output file
-	outFile, err := os.Create(*outputFile)
-	if err != nil {
-		fmt.Println("Failed to create output file:", err)
-		return
-	}
-	defer outFile.Close()
-
-	// Use the appropriate constructor based on the selected kick style
-	var cfg *kick.Settings
+	// Use the appropriate constructor based on the selected kick style.
+	// The output file is created when the kick is rendered.
+	var (
+		cfg *kick.Settings
+		err error
+	)
 	switch {
 	case *kick808:
-		cfg, err = kick.New808(sampleRate, *length/1000.0, *bitDepth, outFile)
+		cfg, err = kick.New808(sampleRate, *length/1000.0, *bitDepth, nil)
 		fmt.Println("Generating 808 kick with deep sub-bass and smooth characteristics.")
 	case *kick909:
-		cfg, err = kick.New909(sampleRate, *length/1000.0, *bitDepth, outFile)
+		cfg, err = kick.New909(sampleRate, *length/1000.0, *bitDepth, nil)
 		fmt.Println("Generating 909 kick with punchy, mid-range presence and quick decay.")
 	case *kick707:
-		cfg, err = kick.New707(sampleRate, *length/1000.0, *bitDepth, outFile)
+		cfg, err = kick.New707(sampleRate, *length/1000.0, *bitDepth, nil)
 		fmt.Println("Generating 707 kick with a classic, shorter punchy sound.")
 	case *kick606:
-		cfg, err = kick.New606(sampleRate, *length/1000.0, *bitDepth, outFile)
+		cfg, err = kick.New606(sampleRate, *length/1000.0, *bitDepth, nil)
 		fmt.Println("Generating 606 kick with a punchy, shorter sound.")
 	case *kickLinnDrum:
-		cfg, err = kick.NewLinnDrum(sampleRate, *length/1000.0, *bitDepth, outFile)
+		cfg, err = kick.NewLinnDrum(sampleRate, *length/1000.0, *bitDepth, nil)
 		fmt.Println("Generating LinnDrum kick with an iconic, punchy sound.")
 	case *kickDeepHouse:
-		cfg, err = kick.NewDeepHouse(sampleRate, *length/1000.0, *bitDepth, outFile)
+		cfg, err = kick.NewDeepHouse(sampleRate, *length/1000.0, *bitDepth, nil)
 		fmt.Println("Generating Deep House kick with smooth, warm bass.")
 	case *kickExperimental:
-		cfg, err = kick.NewExperimental(sampleRate, *length/1000.0, *bitDepth, outFile)
+		cfg, err = kick.NewExperimental(sampleRate, *length/1000.0, *bitDepth, nil)
 		fmt.Println("Generating experimental-style kick with unique texture.")
 	default:
-		cfg, err = kick.NewSettings(150.0, 40.0, sampleRate, *length/1000.0, *bitDepth, outFile)
+		cfg, err = kick.NewSettings(150.0, 40.0, sampleRate, *length/1000.0, *bitDepth, nil)
 		fmt.Println("Generating default kick with user-defined characteristics.")
 	}
 
@@ -121,15 +248,136 @@ func main() {
 	cfg.Release = *release
 	cfg.Sweep = *sweep
 	cfg.FilterCutoff = *filterCutoff
+	cfg.FilterResonance = *filterResonance
+	cfg.FilterDrive = *filterDrive
+	cfg.FilterSlope = *filterSlope
+	cfg.FilterEnvAmount = *filterEnvAmount
 	cfg.PitchDecay = *pitchDecay
 	cfg.Drive = *drive
 	cfg.NumOscillators = *numOscillators
 	cfg.OscillatorLevels = parseCommaSeparatedFloats(*oscillatorLevels)
+	if *oscillatorPhases != "" {
+		cfg.OscillatorPhases = parseCommaSeparatedFloats(*oscillatorPhases)
+	}
+	cfg.StartPhase = *startPhase
+	switch *autoPhase {
+	case "none":
+		cfg.AutoPhase = kick.AutoPhaseNone
+	case "punch":
+		cfg.AutoPhase = kick.AutoPhasePunch
+	case "noclick":
+		cfg.AutoPhase = kick.AutoPhaseNoClick
+	default:
+		fmt.Println("Invalid auto phase. Choose from: none, punch, noclick.")
+		os.Exit(1)
+	}
+	cfg.ClickLevel = *clickLevel
+	cfg.ClickFreq = *clickFreq
+	cfg.ClickDecay = *clickDecay / 1000.0
+	cfg.Velocity = *velocity
+	cfg.VelocityAmp = *velocityAmp
+	cfg.VelocityDrive = *velocityDrive
+	cfg.VelocityPitch = *velocityPitch
+	cfg.VelocityClick = *velocityClick
+	cfg.VelocityCutoff = *velocityCutoff
 	cfg.SaturatorAmount = *saturatorAmount
 	cfg.FilterBands = parseCommaSeparatedFloats(*filterBands)
+	cfg.CompThreshold = *compThreshold
+	cfg.CompRatio = *compRatio
+	cfg.CompAttack = *compAttack
+	cfg.CompRelease = *compRelease
+	cfg.CompKnee = *compKnee
+	cfg.CompMakeup = *compMakeup
+	cfg.CompSidechainHighPass = *compHighPass
+	cfg.TransientAttack = *transientAttack
+	cfg.TransientSustain = *transientSustain
+	cfg.Normalize = *normalize
+	cfg.TargetPeak = *targetPeak
+	cfg.TargetLoudness = *targetLoudness
+	cfg.Limiter = *limiter
+	if *stereo {
+		cfg.Channels = 2
+	}
+	cfg.StereoWidth = *stereoWidth
+	cfg.StereoCrossover = *stereoCrossover
+	cfg.StereoDetune = *stereoDetune
+	cfg.StereoDelay = *stereoDelay / 1000.0
+	cfg.ReverbMix = *reverbMix
+	cfg.ReverbSize = *reverbSize
+	cfg.ReverbDamping = *reverbDamping
+	cfg.ReverbPreDelay = *reverbPreDelay / 1000.0
+	cfg.ReverbLowCut = *reverbLowCut
+	cfg.ReverbTail = *reverbTail / 1000.0
+	cfg.ImpulseResponse = *irFile
+	cfg.IRMix = *irMix
+	cfg.IRLength = *irLength / 1000.0
+	if *eq != "" {
+		bands, err := kick.ParseEQ(*eq)
+		if err != nil {
+			fmt.Println("Invalid EQ:", err)
+			os.Exit(1)
+		}
+		cfg.EQ = bands
+	}
+	if *modulation != "" {
+		sources, routes, err := kick.ParseModulation(*modulation)
+		if err != nil {
+			fmt.Println("Invalid modulation:", err)
+			os.Exit(1)
+		}
+		cfg.ModSources, cfg.ModRoutes = sources, routes
+	}
+	cfg.Tempo = *tempo
+	if *chain != "" {
+		if cfg.Chain, err = kick.ParseChain(*chain); err != nil {
+			fmt.Println("Invalid chain:", err)
+			os.Exit(1)
+		}
+	}
+	cfg.LoFi = lofi
+	cfg.DCBlock = *dcBlock
+	cfg.SubsonicFreq = *subsonicFreq
+	cfg.SubsonicSlope = *subsonicSlope
+	cfg.TrimThreshold = *trim
+	cfg.TrimZeroCrossings = *zeroCrossings
+	cfg.AntiClickFade = *antiClick / 1000.0
+	cfg.BassEnhance = *bassEnhance
+	cfg.BassEnhanceFreq = *bassEnhanceFreq
 	cfg.FadeDuration = 0.01
 	cfg.SmoothFrequencyTransitions = true
 
+	// Set filter model
+	switch *filterModel {
+	case "ladder":
+		cfg.FilterModel = kick.FilterLadder
+	case "diode":
+		cfg.FilterModel = kick.FilterDiode
+	case "sallenkey":
+		cfg.FilterModel = kick.FilterSallenKey
+	case "none":
+		cfg.FilterModel = kick.FilterNone
+	default:
+		fmt.Println("Invalid filter model. Choose from: none, ladder, diode, sallenkey.")
+		os.Exit(1)
+	}
+	if err := cfg.CheckModulation(); err != nil {
+		fmt.Println("Invalid modulation:", err)
+		os.Exit(1)
+	}
+	if *filterSlope != 12 && *filterSlope != 24 {
+		fmt.Println("Invalid filter slope. Choose 12 or 24.")
+		os.Exit(1)
+	}
+	switch *filterEnvSource {
+	case "amp":
+		cfg.FilterEnvSource = kick.FilterEnvAmplitude
+	case "pitch":
+		cfg.FilterEnvSource = kick.FilterEnvPitch
+	default:
+		fmt.Println("Invalid filter envelope source. Choose from: amp, pitch.")
+		os.Exit(1)
+	}
+
 	// Set noise type
 	var noise int
 	switch *noiseType {
@@ -147,14 +395,223 @@ func main() {
 	}
 	cfg.NoiseType = noise
 	cfg.NoiseAmount = *noiseAmount
+	cfg.NoiseAttack = *noiseAttack
+	cfg.NoiseDecay = *noiseDecay
+	cfg.NoiseDelay = *noiseDelay
+	cfg.NoiseSeed = *noiseSeed
+	cfg.NoiseFilterFreq = *noiseFilterFreq
+	cfg.NoiseFilterQ = *noiseFilterQ
+
+	// Set noise filter
+	switch *noiseFilter {
+	case "highpass":
+		cfg.NoiseFilter = kick.NoiseFilterHighPass
+	case "bandpass":
+		cfg.NoiseFilter = kick.NoiseFilterBandPass
+	case "none":
+		cfg.NoiseFilter = kick.NoiseFilterNone
+	default:
+		fmt.Println("Invalid noise filter. Choose from: none, highpass, bandpass.")
+		os.Exit(1)
+	}
+
+	// Tune the kick to a note, or to the nearest note in a key
+	cfg.A4 = *a4
+	cfg.TuneCents = *cents
+	cfg.StartInterval = *interval
+	if *sclFile != "" {
+		scale, err := kick.LoadScala(*sclFile)
+		if err != nil {
+			fmt.Println("Failed to load scale:", err)
+			os.Exit(1)
+		}
+		cfg.Tuning = &kick.Tuning{Scale: scale}
+		if *kbmFile != "" {
+			if cfg.Tuning.Mapping, err = kick.LoadKeyboardMapping(*kbmFile); err != nil {
+				fmt.Println("Failed to load keyboard mapping:", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Println("Using the scale:", scale.Description)
+	} else if *kbmFile != "" {
+		fmt.Println("A keyboard mapping needs a scale, given with -scl")
+		os.Exit(1)
+	}
+
+	// Render a chromatic sample set, one file per note
+	if *chromatic != "" {
+		first, last, err := parseNoteRange(*chromatic)
+		if err != nil {
+			fmt.Println("Invalid note range:", err)
+			os.Exit(1)
+		}
+		ext := filepath.Ext(*outputFile)
+		base := strings.TrimSuffix(*outputFile, ext)
+		for n := first; n <= last; n++ {
+			noteCfg := kick.CopySettings(cfg)
+			if err := noteCfg.SetNoteNumber(n); err != nil {
+				fmt.Printf("Skipping %s: %v\n", kick.NoteName(n), err)
+				continue
+			}
+			fmt.Printf("Tuned to %s: %.2f Hz, with the end frequency at %.2f Hz\n", kick.NoteName(n), noteCfg.NoteFreq, noteCfg.EndFreq)
+			path := fmt.Sprintf("%s_%03d_%s%s", base, n, kick.NoteName(n), ext)
+			if err := render(noteCfg, path, *pitchTolerance, *stems); err != nil {
+				fmt.Println("Failed to generate kick:", err)
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
+	switch {
+	case *note != "":
+		if err := cfg.SetNote(*note); err != nil {
+			fmt.Println("Invalid note:", err)
+			os.Exit(1)
+		}
+		if *key != "" {
+			if k, err := kick.ParseKey(*key); err != nil {
+				fmt.Println("Invalid key:", err)
+				os.Exit(1)
+			} else if n, _ := kick.ParseNote(*note); !k.Contains(n) {
+				fmt.Printf("Warning: %s is not in the key of %s\n", *note, *key)
+			}
+		}
+		fmt.Printf("Tuned to %s: %.2f Hz, with the end frequency at %.2f Hz\n", *note, cfg.NoteFreq, cfg.EndFreq)
+	case *key != "":
+		n, err := cfg.SetKey(*key)
+		if err != nil {
+			fmt.Println("Invalid key:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Tuned to %s, the nearest note in %s: %.2f Hz, with the end frequency at %.2f Hz\n", kick.NoteName(n), *key, cfg.NoteFreq, cfg.EndFreq)
+	}
+
+	// Warn when a tuned kick misses its note
+	tolerance := 0.0
+	if *note != "" || *key != "" {
+		tolerance = *pitchTolerance
+	}
+
+	// Render round-robin variants, with small random deviations
+	if *variants > 0 {
+		variation := kick.Variation{
+			Pitch: *variantPitch,
+			Decay: *variantDecay / 100.0,
+			Click: *variantClick / 100.0,
+			Noise: *variantNoise / 100.0,
+			Phase: *variantPhase,
+		}
+		for i, variant := range cfg.Variants(*variants, variation, *variantSeed) {
+			if err := render(variant, kick.RoundRobinPath(*outputFile, i+1), tolerance, *stems); err != nil {
+				fmt.Println("Failed to generate kick:", err)
+				os.Exit(1)
+			}
+		}
+		return
+	}
 
 	// Generate the kick drum sound
-	if err := cfg.GenerateKick(); err != nil {
+	if err := render(cfg, *outputFile, tolerance, *stems); err != nil {
 		fmt.Println("Failed to generate kick:", err)
-		return
+		os.Exit(1)
 	}
+}
 
-	fmt.Println("Kick drum sound generated and written to", *outputFile)
+// render generates the kick drum sound, writes it to the given path and prints statistics about it.
+// If the tolerance is above 0, a warning is printed when the pitch of the tail is further than that
+// many cents from EndFreq. If stems is true, the oscillators, the noise and the click are also
+// written to files of their own.
+func render(cfg *kick.Settings, path string, tolerance float64, stems bool) error {
+	outFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+	cfg.Output = outFile
+
+	if err := cfg.GenerateKick(); err != nil {
+		return err
+	}
+
+	fmt.Println("Kick drum sound generated and written to", path)
+	if stems {
+		paths, err := cfg.WriteStems(path)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Stems written to", strings.Join(paths, ", "))
+	}
+
+	fmt.Printf("Output gain: %.1f dB, true peak: %.1f dBTP, limiter reduction: %.1f dB\n", cfg.Stats.OutputGain, cfg.Stats.TruePeak, cfg.Stats.LimiterReduction)
+	fmt.Printf("DC offset: %.3f%% before, %.3f%% after the DC blocker and sub-sonic filter\n", cfg.Stats.DCOffsetBefore*100, cfg.Stats.DCOffsetAfter*100)
+	if cfg.Stats.ClippedSamples > 0 || cfg.Stats.ClippedOutput > 0 {
+		fmt.Printf("Clipping: %d samples above full scale before the output stage, %d clipped in the output\n", cfg.Stats.ClippedSamples, cfg.Stats.ClippedOutput)
+	}
+	if cfg.StartPhase != 0 || cfg.AutoPhase != kick.AutoPhaseNone || cfg.PhaseOffset != 0 {
+		fmt.Printf("Start phase: %.0f degrees\n", cfg.Stats.StartPhase)
+	}
+	if cfg.TrimThreshold < 0 || cfg.TrimZeroCrossings {
+		sampleRate := float64(cfg.SampleRate)
+		fmt.Printf("Trimmed %.1f ms from the end, leaving %.1f ms\n", float64(cfg.Stats.TrimmedEnd)/sampleRate*1000, cfg.Stats.Length*1000)
+	}
+	// Measure the loudness of the written file
+	if _, err := outFile.Seek(0, io.SeekStart); err == nil {
+		if loudness, err := kick.MeasureLoudnessWAV(outFile); err != nil {
+			fmt.Println("Could not measure the loudness:", err)
+		} else if loudness.Short {
+			// The short-term window is longer than the kick, so only the momentary loudness is meaningful
+			fmt.Printf("Loudness: %.1f LUFS integrated, %.1f LUFS momentary max, %.1f dBTP true peak\n", loudness.Integrated, loudness.MomentaryMax, loudness.TruePeak)
+		} else {
+			fmt.Printf("Loudness: %.1f LUFS integrated, %.1f LUFS short-term max, %.1f LU range, %.1f dBTP true peak\n", loudness.Integrated, loudness.ShortTermMax, loudness.Range, loudness.TruePeak)
+		}
+	}
+	// Detect the pitch of the tail of the written file
+	if _, err := outFile.Seek(0, io.SeekStart); err == nil {
+		if pitch, err := kick.DetectPitchWAV(outFile); err == nil && pitch.Frequency > 0 {
+			note, cents := kick.FrequencyToNote(pitch.Frequency, cfg.A4)
+			if cfg.NoteFreq > 0 {
+				offset := 1200 * math.Log2(pitch.Frequency/cfg.NoteFreq)
+				fmt.Printf("Tail pitch: %.2f Hz (%s %+.0f cents), %+.0f cents from the tuned note\n", pitch.Frequency, kick.NoteName(note), cents, offset)
+				if tolerance > 0 && math.Abs(offset) > tolerance {
+					fmt.Printf("Warning: the tail pitch misses the tuned note by %+.0f cents\n", offset)
+				}
+			} else {
+				offset := 1200 * math.Log2(pitch.Frequency/cfg.EndFreq)
+				fmt.Printf("Tail pitch: %.2f Hz (%s %+.0f cents), %+.0f cents from the end frequency\n", pitch.Frequency, kick.NoteName(note), cents, offset)
+			}
+		}
+	}
+	if cfg.Channels == 2 {
+		fmt.Printf("Stereo correlation: %.2f overall, %.2f lowest, %.1f dB lost when summed to mono\n", cfg.Stats.Correlation.Correlation, cfg.Stats.Correlation.MinCorrelation, cfg.Stats.Correlation.MonoLoss)
+	}
+	if cfg.CompRatio > 1 {
+		fmt.Printf("Compressor gain reduction: %.1f dB max, %.1f dB average\n", cfg.Stats.MaxGainReduction, cfg.Stats.AverageGainReduction)
+	}
+	return nil
+}
+
+// parseNoteRange parses a range of notes, like C1-B1, into the first and last MIDI note numbers
+func parseNoteRange(s string) (int, int, error) {
+	// Look for the dash after the first note, since octaves may be negative, like C-1
+	for i := 1; i < len(s); i++ {
+		if s[i] != '-' {
+			continue
+		}
+		first, err := kick.ParseNote(s[:i])
+		if err != nil {
+			continue
+		}
+		last, err := kick.ParseNote(s[i+1:])
+		if err != nil {
+			continue
+		}
+		if last < first {
+			return 0, 0, fmt.Errorf("%s is below %s", s[i+1:], s[:i])
+		}
+		return first, last, nil
+	}
+	return 0, 0, fmt.Errorf("expected two notes separated by a dash, like C1-B1, got %q", s)
 }
 
 // parseCommaSeparatedFloats parses a comma-separated string into a slice of float64s
@@ -0,0 +1,80 @@
+package kick
+
+import "math"
+
+// applyCompressor runs the given channels through a feed-forward compressor, configured by the Comp* settings.
+// The gain reduction is computed from the loudest channel and applied to all channels, so that a stereo image
+// does not shift. The largest and the average gain reduction, in dB, are returned.
+func (cfg *Settings) applyCompressor(channels ...[]float64) (maxReduction, avgReduction float64) {
+	if cfg.CompRatio <= 1 || len(channels) == 0 {
+		return 0, 0
+	}
+
+	var sidechain []*biquad
+	if cfg.CompSidechainHighPass > 0 {
+		for range channels {
+			sidechain = append(sidechain, newBiquad(biquadHighPass, cfg.CompSidechainHighPass, math.Sqrt2/2, cfg.SampleRate))
+		}
+	}
+
+	attackCoeff := timeConstant(cfg.CompAttack, cfg.SampleRate)
+	releaseCoeff := timeConstant(cfg.CompRelease, cfg.SampleRate)
+
+	var smoothed, total float64
+	numSamples := len(channels[0])
+	for i := 0; i < numSamples; i++ {
+		// Detect the level of the loudest channel, after the optional sidechain high-pass filter
+		var level float64
+		for c, ch := range channels {
+			x := ch[i]
+			if sidechain != nil {
+				x = sidechain[c].process(x)
+			}
+			level = math.Max(level, math.Abs(x))
+		}
+
+		levelDB := 20 * math.Log10(level+1e-12)
+		reduction := levelDB - compressorGain(levelDB, cfg.CompThreshold, cfg.CompRatio, cfg.CompKnee)
+
+		// Smooth the gain reduction with separate attack and release times
+		if reduction > smoothed {
+			smoothed = attackCoeff*smoothed + (1-attackCoeff)*reduction
+		} else {
+			smoothed = releaseCoeff*smoothed + (1-releaseCoeff)*reduction
+		}
+
+		gain := math.Pow(10, (cfg.CompMakeup-smoothed)/20)
+		for _, ch := range channels {
+			ch[i] *= gain
+		}
+
+		maxReduction = math.Max(maxReduction, smoothed)
+		total += smoothed
+	}
+
+	if numSamples > 0 {
+		avgReduction = total / float64(numSamples)
+	}
+	return maxReduction, avgReduction
+}
+
+// compressorGain is the static curve of the compressor, with a soft knee of the given width in dB
+func compressorGain(levelDB, threshold, ratio, knee float64) float64 {
+	over := levelDB - threshold
+	switch {
+	case 2*over < -knee:
+		return levelDB
+	case knee > 0 && 2*math.Abs(over) <= knee:
+		return levelDB + (1/ratio-1)*(over+knee/2)*(over+knee/2)/(2*knee)
+	default:
+		return threshold + over/ratio
+	}
+}
+
+// timeConstant returns the smoothing coefficient of a one-pole filter that settles in the given time
+func timeConstant(seconds float64, sampleRate int) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return math.Exp(-1 / (seconds * float64(sampleRate)))
+}
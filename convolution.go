@@ -0,0 +1,229 @@
+package kick
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// convolutionBlockSize is the partition size of the partitioned convolution, in samples
+const convolutionBlockSize = 1024
+
+// irCacheKey identifies a prepared impulse response. The modification time is included,
+// so that a changed file is loaded again.
+type irCacheKey struct {
+	path       string
+	sampleRate int
+	maxLength  float64
+	modTime    time.Time
+}
+
+// irCache holds the prepared impulse responses, so that they are not read and resampled on every render
+var (
+	irCache      = make(map[irCacheKey][][]float64)
+	irCacheMutex sync.Mutex
+)
+
+// LoadImpulseResponse reads an impulse response from a WAV file and converts it to the given sample rate.
+// Silence before the first and after the last sample above -60 dB relative to the peak is trimmed away,
+// and if maxLength (in seconds) is above 0, the impulse response is shortened to it, with a short fade-out.
+// The impulse response is normalized to unit energy, so that the wet signal has a predictable level.
+func LoadImpulseResponse(path string, sampleRate int, maxLength float64) ([][]float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	channels, irRate, _, err := ReadWAV(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading impulse response %s: %v", path, err)
+	}
+
+	var peak float64
+	for _, ch := range channels {
+		for _, x := range ch {
+			peak = math.Max(peak, math.Abs(x))
+		}
+	}
+	if peak == 0 {
+		return nil, errors.New("the impulse response is silent")
+	}
+
+	// Find the range that is above the trim threshold, in any of the channels
+	threshold := peak * math.Pow(10, -60.0/20)
+	first, last := len(channels[0]), 0
+	for _, ch := range channels {
+		for i, x := range ch {
+			if math.Abs(x) >= threshold {
+				first = min(first, i)
+				last = max(last, i)
+			}
+		}
+	}
+
+	ir := make([][]float64, len(channels))
+	for c, ch := range channels {
+		ir[c] = resample(ch[first:last+1], irRate, sampleRate)
+	}
+
+	if maxLength > 0 {
+		length := int(maxLength * float64(sampleRate))
+		fade := int(0.01 * float64(sampleRate))
+		for c := range ir {
+			if len(ir[c]) <= length {
+				continue
+			}
+			ir[c] = ir[c][:length]
+			if fade > length {
+				fade = length
+			}
+			for i := 0; i < fade; i++ {
+				ir[c][length-fade+i] *= 1 - float64(i+1)/float64(fade)
+			}
+		}
+	}
+
+	var energy float64
+	for _, ch := range ir {
+		for _, x := range ch {
+			energy += x * x
+		}
+	}
+	gain := 1 / math.Sqrt(energy/float64(len(ir)))
+	for _, ch := range ir {
+		for i := range ch {
+			ch[i] *= gain
+		}
+	}
+
+	return ir, nil
+}
+
+// Convolve convolves the given channels with an impulse response, using uniformly partitioned FFT convolution,
+// and mixes the wet and the dry signal. The returned channels are extended with the tail of the impulse response.
+// A mono impulse response is used for all channels, and a stereo impulse response on a mono signal only uses
+// the first channel of the impulse response.
+func Convolve(channels [][]float64, ir [][]float64, mix float64) [][]float64 {
+	if len(channels) == 0 || len(ir) == 0 {
+		return channels
+	}
+	mix = math.Max(0, math.Min(1, mix))
+
+	out := make([][]float64, len(channels))
+	for c, dry := range channels {
+		response := ir[min(c, len(ir)-1)]
+		wet := convolvePartitioned(dry, response)
+		for i := range wet {
+			wet[i] *= mix
+			if i < len(dry) {
+				wet[i] += dry[i] * (1 - mix)
+			}
+		}
+		out[c] = wet
+	}
+	return out
+}
+
+// convolvePartitioned convolves x with h, by splitting h into blocks that are transformed once, and
+// accumulating the products with the transformed input blocks in a frequency-domain delay line
+func convolvePartitioned(x, h []float64) []float64 {
+	if len(h) == 0 {
+		return append([]float64(nil), x...)
+	}
+	blockSize := convolutionBlockSize
+	fftSize := 2 * blockSize
+	outLength := len(x) + len(h) - 1
+	out := make([]float64, outLength+fftSize)
+
+	// Transform the partitions of the impulse response
+	numPartitions := (len(h) + blockSize - 1) / blockSize
+	partitions := make([][]complex128, numPartitions)
+	for p := range partitions {
+		buf := make([]complex128, fftSize)
+		for i := 0; i < blockSize && p*blockSize+i < len(h); i++ {
+			buf[i] = complex(h[p*blockSize+i], 0)
+		}
+		fft(buf, false)
+		partitions[p] = buf
+	}
+
+	// The frequency-domain delay line holds the most recent transformed input blocks
+	delayLine := make([][]complex128, numPartitions)
+	numBlocks := (outLength + blockSize - 1) / blockSize
+	acc := make([]complex128, fftSize)
+	for block := 0; block < numBlocks; block++ {
+		buf := make([]complex128, fftSize)
+		for i := 0; i < blockSize; i++ {
+			if j := block*blockSize + i; j < len(x) {
+				buf[i] = complex(x[j], 0)
+			}
+		}
+		fft(buf, false)
+		copy(delayLine[1:], delayLine[:numPartitions-1])
+		delayLine[0] = buf
+
+		for i := range acc {
+			acc[i] = 0
+		}
+		for p, input := range delayLine {
+			if input == nil {
+				continue
+			}
+			for i, v := range input {
+				acc[i] += v * partitions[p][i]
+			}
+		}
+		fft(acc, true)
+
+		// Overlap-add the result
+		for i, v := range acc {
+			out[block*blockSize+i] += real(v)
+		}
+	}
+
+	return out[:outLength]
+}
+
+// applyConvolution convolves the channels with the impulse response in ImpulseResponse, if it is set
+func (cfg *Settings) applyConvolution(channels [][]float64) ([][]float64, error) {
+	if cfg.ImpulseResponse == "" {
+		return channels, nil
+	}
+	ir, err := cachedImpulseResponse(cfg.ImpulseResponse, cfg.SampleRate, cfg.IRLength)
+	if err != nil {
+		return nil, err
+	}
+	return Convolve(channels, ir, cfg.IRMix), nil
+}
+
+// cachedImpulseResponse returns the impulse response from LoadImpulseResponse, and loads it only once
+// for each path, sample rate and length. The returned channels must not be modified.
+func cachedImpulseResponse(path string, sampleRate int, maxLength float64) ([][]float64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	key := irCacheKey{path, sampleRate, maxLength, info.ModTime()}
+
+	irCacheMutex.Lock()
+	defer irCacheMutex.Unlock()
+	if ir, ok := irCache[key]; ok {
+		return ir, nil
+	}
+	ir, err := LoadImpulseResponse(path, sampleRate, maxLength)
+	if err != nil {
+		return nil, err
+	}
+	// Drop the impulse responses of earlier versions of the file
+	for k := range irCache {
+		if k.path == path && !k.modTime.Equal(key.modTime) {
+			delete(irCache, k)
+		}
+	}
+	irCache[key] = ir
+	return ir, nil
+}
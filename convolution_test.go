@@ -0,0 +1,49 @@
+package kick
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// The partitioned convolution should match the direct form, also with several partitions
+func TestConvolvePartitioned(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	noise := func(n int) []float64 {
+		samples := make([]float64, n)
+		for i := range samples {
+			samples[i] = r.Float64()*2 - 1
+		}
+		return samples
+	}
+	for _, sizes := range [][2]int{{100, 1}, {5000, 300}, {3000, 2*convolutionBlockSize + 17}} {
+		x, h := noise(sizes[0]), noise(sizes[1])
+		want := make([]float64, len(x)+len(h)-1)
+		for i, a := range x {
+			for j, b := range h {
+				want[i+j] += a * b
+			}
+		}
+		got := convolvePartitioned(x, h)
+		if len(got) != len(want) {
+			t.Fatalf("got %d samples, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if math.Abs(got[i]-want[i]) > 1e-9 {
+				t.Fatalf("%d samples with a %d sample impulse response: sample %d is %g, want %g", sizes[0], sizes[1], i, got[i], want[i])
+			}
+		}
+	}
+}
+
+// A dry mix should leave the signal as it is, extended by the length of the impulse response
+func TestConvolveDry(t *testing.T) {
+	x := []float64{1, 0.5, -0.25}
+	out := Convolve([][]float64{x}, [][]float64{{0.5, 0.5}}, 0)
+	want := []float64{1, 0.5, -0.25, 0}
+	for i := range want {
+		if math.Abs(out[0][i]-want[i]) > 1e-12 {
+			t.Fatalf("sample %d is %g, want %g", i, out[0][i], want[i])
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package kick
+
+import (
+	"fmt"
+	"math"
+)
+
+// dcBlockerFrequency is the corner frequency of the DC blocker, in Hz
+const dcBlockerFrequency = 5.0
+
+// dcOffset returns the DC offset of the given channels, as the mean sample value of the channel that is furthest from 0
+func dcOffset(channels ...[]float64) float64 {
+	var offset float64
+	for _, ch := range channels {
+		if len(ch) == 0 {
+			continue
+		}
+		var sum float64
+		for _, x := range ch {
+			sum += x
+		}
+		if mean := sum / float64(len(ch)); math.Abs(mean) > math.Abs(offset) {
+			offset = mean
+		}
+	}
+	return offset
+}
+
+// applyDCBlocker removes DC offset from the given channels, with a one-pole high-pass filter
+func applyDCBlocker(sampleRate int, channels ...[]float64) {
+	r := math.Exp(-2 * math.Pi * dcBlockerFrequency / float64(sampleRate))
+	for _, ch := range channels {
+		var x1, y1 float64
+		for i, x := range ch {
+			y := x - x1 + r*y1
+			x1, y1 = x, y
+			ch[i] = y
+		}
+	}
+}
+
+// applyCleanup runs the DC blocker, if DCBlock is set, and the sub-sonic high-pass filter at SubsonicFreq,
+// if it is above 0, at the end of the chain, where only the fade-out comes after it. The DC offset before
+// and after is recorded in cfg.Stats. An error is returned if SubsonicSlope is not 12, 24, 36 or 48.
+func (cfg *Settings) applyCleanup(channels ...[]float64) error {
+	cfg.Stats.DCOffsetBefore = dcOffset(channels...)
+	if cfg.DCBlock {
+		applyDCBlocker(cfg.SampleRate, channels...)
+	}
+	if cfg.SubsonicFreq > 0 {
+		switch cfg.SubsonicSlope {
+		case 12, 24, 36, 48:
+		default:
+			return fmt.Errorf("invalid sub-sonic slope %d, choose from: 12, 24, 36, 48", cfg.SubsonicSlope)
+		}
+		ApplyEQ(cfg.SampleRate, []EQBand{{Type: EQHighPass, Freq: cfg.SubsonicFreq, Q: math.Sqrt2 / 2, Slope: cfg.SubsonicSlope}}, channels...)
+	}
+	cfg.Stats.DCOffsetAfter = dcOffset(channels...)
+	return nil
+}
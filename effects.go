@@ -2,28 +2,36 @@ package kick
 
 import "math"
 
-func applySaturator(samples []int, amount float64) {
+// toFloats converts samples of the given bit depth to floats in the -1 to 1 range
+func toFloats(samples []int, bitDepth int) []float64 {
+	fullScale := float64(int(1) << (bitDepth - 1))
+	floats := make([]float64, len(samples))
+	for i, sample := range samples {
+		floats[i] = float64(sample) / fullScale
+	}
+	return floats
+}
+
+func applySaturator(samples []float64, amount float64) {
 	for i := range samples {
-		sample := float64(samples[i]) / float64(1<<(16-1))
-		sample = math.Tanh(sample * (1.0 + amount))
-		samples[i] = int(sample * float64(1<<15))
+		samples[i] = math.Tanh(samples[i] * (1.0 + amount))
 	}
 }
 
-func applyMultiBandFiltering(samples []int, bands []float64, sampleRate int) {
+func applyMultiBandFiltering(samples []float64, bands []float64, sampleRate int) {
 	numSamples := len(samples)
 	for i := 0; i < numSamples; i++ {
 		t := float64(i) / float64(sampleRate)
 		frequency := 440.0 * math.Pow(2.0, t)
 
 		if frequency < bands[0] {
-			samples[i] = int(float64(samples[i]) * 0.9)
+			samples[i] *= 0.9
 		} else if frequency < bands[1] {
-			samples[i] = int(float64(samples[i]) * 0.8)
+			samples[i] *= 0.8
 		} else if frequency < bands[2] {
-			samples[i] = int(float64(samples[i]) * 0.7)
+			samples[i] *= 0.7
 		} else {
-			samples[i] = int(float64(samples[i]) * 0.6)
+			samples[i] *= 0.6
 		}
 	}
 }
@@ -51,21 +59,21 @@ func applyEnvelope(t, attack, decay, sustain, release, duration float64) float64
 	return 0.0
 }
 
-func applyFadeInOut(samples []int, sampleRate int, fadeDuration float64) {
+func applyFadeInOut(samples []float64, sampleRate int, fadeDuration float64, fadeIn bool) {
 	fadeSamples := int(fadeDuration * float64(sampleRate))
 	if fadeSamples > len(samples)/2 {
 		fadeSamples = len(samples) / 2
 	}
 
 	// Apply fade-in
-	for i := 0; i < fadeSamples; i++ {
+	for i := 0; fadeIn && i < fadeSamples; i++ {
 		fadeFactor := float64(i) / float64(fadeSamples)
-		samples[i] = int(float64(samples[i]) * fadeFactor)
+		samples[i] *= fadeFactor
 	}
 
 	// Apply fade-out
 	for i := len(samples) - fadeSamples; i < len(samples); i++ {
 		fadeFactor := float64(len(samples)-i) / float64(fadeSamples)
-		samples[i] = int(float64(samples[i]) * fadeFactor)
+		samples[i] *= fadeFactor
 	}
 }
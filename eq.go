@@ -0,0 +1,132 @@
+package kick
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	EQPeak = iota
+	EQLowShelf
+	EQHighShelf
+	EQNotch
+	EQHighPass
+	EQLowPass
+)
+
+// EQBand is one band of the parametric EQ
+type EQBand struct {
+	Type  int     // EQPeak, EQLowShelf, EQHighShelf, EQNotch, EQHighPass or EQLowPass
+	Freq  float64 // center, corner or cutoff frequency, in Hz
+	Gain  float64 // gain in dB, for the peak and shelf bands
+	Q     float64 // bandwidth of the peak and notch bands, or the resonance of a 12 dB/octave pass band
+	Slope int     // slope of the pass bands in dB/octave: 12, 24, 36 or 48
+}
+
+// eqTypeNames are the band types, as used by ParseEQ
+var eqTypeNames = map[string]int{
+	"peak":  EQPeak,
+	"ls":    EQLowShelf,
+	"hs":    EQHighShelf,
+	"notch": EQNotch,
+	"hp":    EQHighPass,
+	"lp":    EQLowPass,
+}
+
+// newFilters returns the biquad sections for the band. High-pass and low-pass bands that are steeper
+// than 12 dB/octave are built as Butterworth filters from several sections.
+func (band EQBand) newFilters(sampleRate int) []*biquad {
+	switch band.Type {
+	case EQHighPass, EQLowPass:
+		kind := biquadHighPass
+		if band.Type == EQLowPass {
+			kind = biquadLowPass
+		}
+		sections := band.Slope / 12
+		if sections <= 1 {
+			return []*biquad{newBiquad(kind, band.Freq, band.Q, sampleRate)}
+		}
+		var filters []*biquad
+		order := 2 * sections
+		for k := 1; k <= sections; k++ {
+			q := 1 / (2 * math.Sin(float64(2*k-1)*math.Pi/float64(2*order)))
+			filters = append(filters, newBiquad(kind, band.Freq, q, sampleRate))
+		}
+		return filters
+	case EQLowShelf:
+		return []*biquad{newGainBiquad(biquadLowShelf, band.Freq, band.Q, band.Gain, sampleRate)}
+	case EQHighShelf:
+		return []*biquad{newGainBiquad(biquadHighShelf, band.Freq, band.Q, band.Gain, sampleRate)}
+	case EQNotch:
+		return []*biquad{newBiquad(biquadNotch, band.Freq, band.Q, sampleRate)}
+	default: // EQPeak
+		return []*biquad{newGainBiquad(biquadPeak, band.Freq, band.Q, band.Gain, sampleRate)}
+	}
+}
+
+// ApplyEQ runs the given channels through the bands of a parametric EQ
+func ApplyEQ(sampleRate int, bands []EQBand, channels ...[]float64) {
+	for _, band := range bands {
+		for _, ch := range channels {
+			for _, f := range band.newFilters(sampleRate) {
+				for i, x := range ch {
+					ch[i] = f.process(x)
+				}
+			}
+		}
+	}
+}
+
+// ParseEQ parses a comma-separated list of EQ bands. Each band is written as type:freq, followed by
+// :gain:q for the peak and shelf bands, :q for notch bands or :slope for the pass bands, where the
+// trailing values can be left out. The types are peak, ls, hs, notch, hp and lp.
+// For example: "hp:30:24,peak:60:3:1.4,peak:300:-4:2,hs:6000:2"
+func ParseEQ(input string) ([]EQBand, error) {
+	var bands []EQBand
+	for _, field := range strings.Split(input, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		parts := strings.Split(field, ":")
+		bandType, ok := eqTypeNames[strings.ToLower(parts[0])]
+		if !ok {
+			return nil, fmt.Errorf("unknown EQ band type %q in %q", parts[0], field)
+		}
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("missing frequency in EQ band %q", field)
+		}
+		values := make([]float64, len(parts)-1)
+		for i, part := range parts[1:] {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q in EQ band %q", part, field)
+			}
+			values[i] = v
+		}
+		value := func(i int, defaultValue float64) float64 {
+			if i < len(values) {
+				return values[i]
+			}
+			return defaultValue
+		}
+
+		band := EQBand{Type: bandType, Freq: values[0], Q: math.Sqrt2 / 2, Slope: 12}
+		switch bandType {
+		case EQPeak, EQLowShelf, EQHighShelf:
+			band.Gain = value(1, 0)
+			band.Q = value(2, band.Q)
+		case EQNotch:
+			band.Q = value(1, 4)
+		case EQHighPass, EQLowPass:
+			band.Slope = int(value(1, 12))
+			if band.Slope%12 != 0 || band.Slope < 12 || band.Slope > 48 {
+				return nil, fmt.Errorf("invalid slope in EQ band %q, choose 12, 24, 36 or 48", field)
+			}
+		}
+		bands = append(bands, band)
+	}
+	return bands, nil
+}
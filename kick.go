@@ -7,7 +7,6 @@ import (
 	"image/color"
 	"io"
 	"math"
-	"math/rand"
 	"os"
 	"os/exec"
 
@@ -35,6 +34,11 @@ const (
 type Settings struct {
 	StartFreq                  float64
 	EndFreq                    float64
+	A4                         float64
+	TuneCents                  float64
+	StartInterval              float64
+	Tuning                     *Tuning
+	NoteFreq                   float64
 	SampleRate                 int
 	Duration                   float64
 	WaveformType               int
@@ -45,25 +49,92 @@ type Settings struct {
 	Drive                      float64
 	FilterCutoff               float64
 	FilterResonance            float64
+	FilterModel                int
+	FilterDrive                float64
+	FilterSlope                int
+	FilterEnvAmount            float64
+	FilterEnvSource            int
 	Sweep                      float64
 	PitchDecay                 float64
 	NoiseType                  int
 	NoiseAmount                float64
+	NoiseAttack                float64
+	NoiseDecay                 float64
+	NoiseDelay                 float64
+	NoiseFilter                int
+	NoiseFilterFreq            float64
+	NoiseFilterQ               float64
+	NoiseSeed                  int64
 	Output                     io.WriteSeeker
 	NumOscillators             int
 	OscillatorLevels           []float64
+	OscillatorPhases           []float64
+	StartPhase                 float64
+	AutoPhase                  int
+	PhaseOffset                float64
+	ClickLevel                 float64
+	ClickFreq                  float64
+	ClickDecay                 float64
+	Velocity                   float64
+	VelocityAmp                float64
+	VelocityDrive              float64
+	VelocityPitch              float64
+	VelocityClick              float64
+	VelocityCutoff             float64
+	Tempo                      float64
+	ModSources                 []ModSource
+	ModRoutes                  []ModRoute
+	Chain                      []string
 	SaturatorAmount            float64
 	FilterBands                []float64
+	EQ                         []EQBand
+	BassEnhance                float64
+	BassEnhanceFreq            float64
 	BitDepth                   int
 	FadeDuration               float64
 	SmoothFrequencyTransitions bool
+	CompThreshold              float64
+	CompRatio                  float64
+	CompAttack                 float64
+	CompRelease                float64
+	CompKnee                   float64
+	CompMakeup                 float64
+	CompSidechainHighPass      float64
+	TransientAttack            float64
+	TransientSustain           float64
+	Normalize                  bool
+	TargetPeak                 float64
+	TargetLoudness             float64
+	Limiter                    bool
+	LimiterLookahead           float64
+	LimiterRelease             float64
+	Channels                   int
+	StereoWidth                float64
+	StereoCrossover            float64
+	StereoDetune               float64
+	StereoDelay                float64
+	ReverbMix                  float64
+	ReverbSize                 float64
+	ReverbDamping              float64
+	ReverbPreDelay             float64
+	ReverbLowCut               float64
+	ReverbTail                 float64
+	LoFi                       LoFi
+	DCBlock                    bool
+	SubsonicFreq               float64
+	SubsonicSlope              int
+	ImpulseResponse            string
+	IRMix                      float64
+	IRLength                   float64
+	TrimThreshold              float64
+	TrimZeroCrossings          bool
+	AntiClickFade              float64
+	Stats                      RenderStats
+
+	// startPhaseSet overrides hasStartPhase, for stems that pin the start phase that was picked for the mix
+	startPhaseSet *bool
 }
 
-var (
-	brownNoiseAccumulator float64
-	pinkNoiseAccumulator  float64
-)
-
 func NewSettings(startFreq, endFreq float64, sampleRate int, duration float64, bitDepth int, output io.WriteSeeker) (*Settings, error) {
 	if sampleRate <= 0 || duration <= 0 {
 		return nil, errors.New("invalid sample rate or duration")
@@ -72,6 +143,7 @@ func NewSettings(startFreq, endFreq float64, sampleRate int, duration float64, b
 	return &Settings{
 		StartFreq:        startFreq,
 		EndFreq:          endFreq,
+		A4:               440,
 		SampleRate:       sampleRate,
 		Duration:         duration,
 		WaveformType:     WaveSine,
@@ -82,16 +154,58 @@ func NewSettings(startFreq, endFreq float64, sampleRate int, duration float64, b
 		Drive:            0.2,
 		FilterCutoff:     5000,
 		FilterResonance:  0.2,
+		FilterModel:      FilterNone,
+		FilterSlope:      24,
+		FilterEnvSource:  FilterEnvAmplitude,
 		Sweep:            0.7,
 		PitchDecay:       0.4,
 		NoiseType:        NoiseNone,
 		NoiseAmount:      0.0,
+		NoiseAttack:      0.0005,
+		NoiseDecay:       0.05,
+		NoiseFilter:      NoiseFilterHighPass,
+		NoiseFilterFreq:  2000,
+		NoiseFilterQ:     0.707,
 		Output:           output,
 		NumOscillators:   1,
 		OscillatorLevels: []float64{1.0},
 		SaturatorAmount:  0.3,
 		FilterBands:      []float64{200.0, 1000.0, 3000.0},
 		BitDepth:         bitDepth,
+		BassEnhanceFreq:  120,
+		CompThreshold:    -12.0,
+		CompRatio:        1.0,
+		CompAttack:       0.01,
+		CompRelease:      0.1,
+		CompKnee:         6.0,
+		Normalize:        true,
+		TargetPeak:       -1.0,
+		Limiter:          true,
+		LimiterLookahead: 0.0015,
+		LimiterRelease:   0.05,
+		Channels:         1,
+		StereoWidth:      0.5,
+		StereoCrossover:  150,
+		StereoDetune:     10,
+		StereoDelay:      0.0003,
+		ReverbSize:       0.5,
+		ReverbDamping:    0.5,
+		ReverbPreDelay:   0.01,
+		ReverbLowCut:     200,
+		IRMix:            1.0,
+		DCBlock:          true,
+		SubsonicFreq:     20,
+		SubsonicSlope:    24,
+		AntiClickFade:    0.001,
+		ClickFreq:        3000,
+		ClickDecay:       0.004,
+		Velocity:         1,
+		VelocityAmp:      1,
+		VelocityDrive:    0.5,
+		VelocityPitch:    0.5,
+		VelocityClick:    1,
+		VelocityCutoff:   2,
+		Tempo:            120,
 	}, nil
 }
 
@@ -99,6 +213,12 @@ func NewSettings(startFreq, endFreq float64, sampleRate int, duration float64, b
 func CopySettings(cfg *Settings) *Settings {
 	newCfg := *cfg
 	newCfg.OscillatorLevels = append([]float64(nil), cfg.OscillatorLevels...) // Deep copy the slice
+	newCfg.OscillatorPhases = append([]float64(nil), cfg.OscillatorPhases...)
+	newCfg.FilterBands = append([]float64(nil), cfg.FilterBands...)
+	newCfg.EQ = append([]EQBand(nil), cfg.EQ...)
+	newCfg.ModSources = append([]ModSource(nil), cfg.ModSources...)
+	newCfg.ModRoutes = append([]ModRoute(nil), cfg.ModRoutes...)
+	newCfg.Chain = append([]string(nil), cfg.Chain...)
 	return &newCfg
 }
 
@@ -122,6 +242,11 @@ func PlayWav(filePath string) error {
 
 // PlayWaveform writes the waveform to a temporary .wav file and plays it using mpv or ffmpeg
 func PlayWaveform(wave []int, sampleRate int) error {
+	return playInterleaved(wave, sampleRate, 1)
+}
+
+// playInterleaved writes interleaved 16-bit samples to a temporary .wav file and plays it using mpv or ffmpeg
+func playInterleaved(wave []int, sampleRate, numChannels int) error {
 	// Create a temporary file
 	file, err := os.CreateTemp("", "waveform_*.wav")
 	if err != nil {
@@ -133,11 +258,11 @@ func PlayWaveform(wave []int, sampleRate int) error {
 	// Write the waveform as a .wav file
 	buffer := &audio.IntBuffer{
 		Data:           wave,
-		Format:         &audio.Format{SampleRate: sampleRate, NumChannels: 1},
+		Format:         &audio.Format{SampleRate: sampleRate, NumChannels: numChannels},
 		SourceBitDepth: 16,
 	}
 
-	encoder := wav.NewEncoder(file, sampleRate, 16, 1, 1)
+	encoder := wav.NewEncoder(file, sampleRate, 16, numChannels, 1)
 	if err := encoder.Write(buffer); err != nil {
 		return fmt.Errorf("Error writing waveform to WAV file: %v", err)
 	}
@@ -182,8 +307,19 @@ func (cfg *Settings) Play() error {
 		return err
 	}
 
+	// playInterleaved expects 16-bit samples
+	if cfg.BitDepth > 16 {
+		for i := range waveform {
+			waveform[i] >>= cfg.BitDepth - 16
+		}
+	} else if cfg.BitDepth < 16 {
+		for i := range waveform {
+			waveform[i] <<= 16 - cfg.BitDepth
+		}
+	}
+
 	// Play the generated waveform directly
-	err = PlayWaveform(waveform, cfg.SampleRate)
+	err = playInterleaved(waveform, cfg.SampleRate, cfg.numChannels())
 	if err != nil {
 		return fmt.Errorf("Error playing generated waveform: %v", err)
 	}
@@ -191,28 +327,31 @@ func (cfg *Settings) Play() error {
 }
 
 func (cfg *Settings) GenerateKick() error {
-	samples := cfg.generateMultiOscillatorSamples()
-
-	applySaturator(samples, cfg.SaturatorAmount)
-
-	applyMultiBandFiltering(samples, cfg.FilterBands, cfg.SampleRate)
-
-	if cfg.NoiseType != NoiseNone {
-		mixNoise(samples, cfg)
+	channels, err := cfg.renderChannels()
+	if err != nil {
+		return err
 	}
 
-	// Apply fade in/out if FadeDuration is set
-	if cfg.FadeDuration > 0 {
-		applyFadeInOut(samples, cfg.SampleRate, cfg.FadeDuration)
-	}
+	return cfg.writeOutput(cfg.applyOutputStage(channels...), len(channels))
+}
 
+// writeOutput encodes interleaved samples from the output stage as a WAV file to Output
+func (cfg *Settings) writeOutput(data []int, numChannels int) error {
+	if cfg.BitDepth == 8 {
+		// 8-bit WAV samples are unsigned
+		unsigned := make([]int, len(data))
+		for i, sample := range data {
+			unsigned[i] = sample + 128
+		}
+		data = unsigned
+	}
 	buffer := &audio.IntBuffer{
-		Data:           samples,
-		Format:         &audio.Format{SampleRate: cfg.SampleRate, NumChannels: 1},
+		Data:           data,
+		Format:         &audio.Format{SampleRate: cfg.SampleRate, NumChannels: numChannels},
 		SourceBitDepth: cfg.BitDepth,
 	}
 
-	encoder := wav.NewEncoder(cfg.Output, cfg.SampleRate, cfg.BitDepth, 1, 1)
+	encoder := wav.NewEncoder(cfg.Output, cfg.SampleRate, cfg.BitDepth, numChannels, 1)
 	if err := encoder.Write(buffer); err != nil {
 		return err
 	}
@@ -220,58 +359,104 @@ func (cfg *Settings) GenerateKick() error {
 	return encoder.Close()
 }
 
-func (cfg *Settings) generateMultiOscillatorSamples() []int {
+// renderChannels renders the kick up to the output stage, as one or two channels of floats,
+// by generating it and running it through the effects chain
+func (cfg *Settings) renderChannels() ([][]float64, error) {
+	return cfg.applyChain(cfg.generateChannels())
+}
+
+// generateMultiOscillatorSamples generates the oscillators and the noise layer, with the oscillators detuned by the given number of cents
+func (cfg *Settings) generateMultiOscillatorSamples(detune float64, channel int) []float64 {
 	numSamples := int(float64(cfg.SampleRate) * cfg.Duration)
-	samples := make([]int, numSamples)
+	samples := make([]float64, numSamples)
+
+	startFreq := cfg.startFreq()
+	drive := cfg.drive()
+	pitchMod := generatePitchModulation(startFreq, cfg.EndFreq, cfg.SampleRate, cfg.Duration)
+
+	// The noise layer is mixed in here, before the saturator, so that it can add grit to the attack
+	noise := cfg.newNoiseLayer(channel)
+	click := cfg.newClickLayer()
 
-	pitchMod := generatePitchModulation(cfg.StartFreq, cfg.EndFreq, cfg.SampleRate, cfg.Duration)
+	tone := cfg.newToneFilter()
+	oscNoise := cfg.newNoiseSource(channel, 0)
+
+	mod := cfg.newModulator()
+	modResonance := cfg.hasModRoute(ModResonance)
+
+	startPhase := cfg.startPhase()
+	cfg.Stats.StartPhase = startPhase
+
+	// A start phase is there for the instant-on transient, so it should not be ramped in by the attack
+	attack := cfg.Attack
+	if cfg.hasStartPhase() {
+		attack = 0
+	}
+
+	// The phase is integrated over the frequency, so that the pitch follows the sweep
+	var cycles float64
 
 	for i := 0; i < numSamples; i++ {
 		t := float64(i) / float64(cfg.SampleRate)
 		var totalSample float64
 
-		for oscIndex := 0; oscIndex < cfg.NumOscillators; oscIndex++ {
-			var frequency float64
-			if cfg.SmoothFrequencyTransitions {
-				// Smoother frequency transition
-				decayFactor := math.Pow(cfg.EndFreq/cfg.StartFreq, (t/cfg.Duration)*cfg.Sweep)
-				frequency = cfg.StartFreq * decayFactor * pitchMod[i]
+		var frequency float64
+		if cfg.SmoothFrequencyTransitions {
+			// Smoother frequency transition
+			decayFactor := math.Pow(cfg.EndFreq/startFreq, (t/cfg.Duration)*cfg.Sweep)
+			frequency = startFreq * decayFactor * pitchMod[i]
+		} else {
+			// Abrupt frequency transition
+			if t < cfg.Duration/2 {
+				frequency = startFreq
 			} else {
-				// Abrupt frequency transition
-				if t < cfg.Duration/2 {
-					frequency = cfg.StartFreq
-				} else {
-					frequency = cfg.EndFreq
-				}
+				frequency = cfg.EndFreq
 			}
+		}
+
+		frequency *= math.Pow(2, detune/1200)
+
+		envelopeValue := applyEnvelope(t, attack, cfg.Decay, cfg.Sustain, cfg.Release, cfg.Duration)
+
+		mod.update(t)
+		sampleDrive := math.Max(0, drive+mod.offset(ModDrive, 0))
 
+		for oscIndex := 0; oscIndex < cfg.NumOscillators; oscIndex++ {
 			var sample float64
 			switch cfg.WaveformType {
-			case WaveSine:
-				sample = math.Sin(2 * math.Pi * frequency * t)
-			case WaveTriangle:
-				sample = 2*math.Abs(2*((t*frequency)-math.Floor((t*frequency)+0.5))) - 1
-			case WaveSawtooth:
-				sample = 2 * (t*frequency - math.Floor(0.5+t*frequency))
-			case WaveSquare:
-				sample = math.Copysign(1.0, math.Sin(2*math.Pi*frequency*t))
+			case WaveSine, WaveTriangle, WaveSawtooth, WaveSquare:
+				sample = periodicWave(cfg.WaveformType, cycles+(startPhase+cfg.oscillatorPhase(oscIndex))/360)
 			case WaveNoiseWhite:
-				sample = rand.Float64()*2 - 1
+				sample = oscNoise.white()
 			case WaveNoisePink:
-				sample = generatePinkNoise(i)
+				sample = oscNoise.pink(i)
 			case WaveNoiseBrown:
-				sample = generateBrownNoise(i)
+				sample = oscNoise.brown()
 			}
 
-			sample = applyDrive(sample, cfg.Drive)
-			envelopeValue := applyEnvelope(t, cfg.Attack, cfg.Decay, cfg.Sustain, cfg.Release, cfg.Duration)
+			sample = applyDrive(sample, sampleDrive)
 			sample *= envelopeValue
 
-			sample *= cfg.OscillatorLevels[oscIndex]
+			sample *= cfg.OscillatorLevels[oscIndex] * math.Max(0, 1+mod.offset(ModOscillatorLevel, oscIndex+1))
 			totalSample += sample
 		}
 
-		samples[i] = int(totalSample * float64(int(1)<<cfg.BitDepth-1))
+		if tone != nil {
+			if modResonance {
+				tone.setResonance(cfg.FilterResonance + mod.offset(ModResonance, 0))
+			}
+			cutoffMod := math.Pow(2, mod.offset(ModCutoff, 0))
+			if cfg.FilterEnvSource == FilterEnvPitch {
+				totalSample = tone.process(totalSample, cfg.filterCutoff(cfg.pitchEnvelope(frequency))*cutoffMod)
+			} else {
+				totalSample = tone.process(totalSample, cfg.filterCutoff(envelopeValue)*cutoffMod)
+			}
+		}
+
+		totalSample += noise.sample(i)*math.Max(0, 1+mod.offset(ModNoiseAmount, 0)) + click.sample(i)
+		cycles += frequency / float64(cfg.SampleRate)
+
+		samples[i] = totalSample
 	}
 
 	return samples
@@ -289,43 +474,6 @@ func generatePitchModulation(startFreq, endFreq float64, sampleRate int, duratio
 	return pitchMod
 }
 
-func generatePinkNoise(i int) float64 {
-	pinkNoiseAccumulator += rand.Float64()*2 - 1
-	return pinkNoiseAccumulator / float64(i+1)
-}
-
-func generateBrownNoise(i int) float64 {
-	brownNoiseAccumulator += (rand.Float64()*2 - 1) * 0.1
-	if brownNoiseAccumulator > 1 {
-		brownNoiseAccumulator = 1
-	} else if brownNoiseAccumulator < -1 {
-		brownNoiseAccumulator = -1
-	}
-	return brownNoiseAccumulator
-}
-
-func mixNoise(samples []int, cfg *Settings) {
-	for i := range samples {
-		var noiseSample float64
-		switch cfg.NoiseType {
-		case NoiseWhite:
-			noiseSample = rand.Float64()*2 - 1
-		case NoisePink:
-			noiseSample = generatePinkNoise(i)
-		case NoiseBrown:
-			noiseSample = generateBrownNoise(i)
-		}
-
-		samples[i] += int(noiseSample * cfg.NoiseAmount * float64(int(1)<<(cfg.BitDepth-1)))
-
-		if samples[i] > 32767 {
-			samples[i] = 32767
-		} else if samples[i] < -32767 {
-			samples[i] = -32767
-		}
-	}
-}
-
 // Color returns a color that very approximately represents the current kick config
 func (cfg *Settings) Color() color.RGBA {
 	hasher := sha1.New()
@@ -340,14 +488,20 @@ func (cfg *Settings) Color() color.RGBA {
 }
 
 // GenerateKickInMemory generates the kick waveform and returns it as a slice of integers.
+// The samples are signed at every bit depth, also for 8-bit, where the WAV file holds unsigned samples.
+// For stereo output, the samples of the left and the right channel are interleaved.
 func (cfg *Settings) GenerateKickInMemory() ([]int, error) {
-	samples := cfg.generateMultiOscillatorSamples()
-	applySaturator(samples, cfg.SaturatorAmount)
-	applyMultiBandFiltering(samples, cfg.FilterBands, cfg.SampleRate)
-
-	if cfg.NoiseType != NoiseNone {
-		mixNoise(samples, cfg)
+	channels, err := cfg.renderChannels()
+	if err != nil {
+		return nil, err
 	}
+	return cfg.applyOutputStage(channels...), nil
+}
 
-	return samples, nil
+// numChannels returns 2 for stereo output and 1 for mono output
+func (cfg *Settings) numChannels() int {
+	if cfg.Channels == 2 || cfg.hasModRoute(ModPan) {
+		return 2
+	}
+	return 1
 }
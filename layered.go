@@ -0,0 +1,251 @@
+package kick
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Layer is a kick that is rendered on its own and then stacked with other layers in a Layered kick
+type Layer struct {
+	Settings  *Settings
+	Gain      float64        // in dB
+	Offset    float64        // delay of the layer, in seconds
+	Invert    bool           // flip the polarity of the layer
+	HighPass  float64        // high-pass frequency of the layer, 24 dB/octave, in Hz (0 to disable)
+	LowPass   float64        // low-pass frequency of the layer, 24 dB/octave, in Hz (0 to disable)
+	Mute      bool           // leave the layer out
+	Solo      bool           // leave out all layers that are not soloed
+	Alignment LayerAlignment // how the layer lined up with the layers before it, the last time it was mixed
+	placed    [][]float64    // the layer as it was placed in the last mix, for the stems
+}
+
+// LayerAlignment holds how a layer lined up with the sum of the layers before it
+type LayerAlignment struct {
+	Cancellation float64 // level lost by adding the layer, compared to adding the energy of both, in dB
+	Shift        float64 // delay added by the phase alignment, in seconds
+	Flipped      bool    // true if the phase alignment flipped the polarity of the layer
+	Remaining    float64 // level lost by adding the layer after the phase alignment, in dB
+}
+
+// Layered is a kick that is stacked from several layers, like a sub, a mid punch and a top click.
+// The layers are mixed and then sent through the output stage of Master, which also holds the
+// sample rate, the bit depth and the Output to write to.
+type Layered struct {
+	Master     *Settings
+	Layers     []*Layer
+	AlignPhase bool    // shift and flip layers to avoid cancellation with the layers before them
+	MaxShift   float64 // largest delay that the phase alignment may add to a layer, in seconds
+}
+
+// alignmentWindow is how much of the start of the layers is compared by the phase alignment, in seconds
+const alignmentWindow = 0.3
+
+// NewLayered creates a layered kick, with the output stage of the given settings
+func NewLayered(master *Settings) *Layered {
+	return &Layered{Master: master, MaxShift: 0.005}
+}
+
+// AddLayer adds the given settings as a layer at full gain, and returns it so that it can be adjusted
+func (l *Layered) AddLayer(cfg *Settings) *Layer {
+	layer := &Layer{Settings: cfg}
+	l.Layers = append(l.Layers, layer)
+	return layer
+}
+
+// activeLayers returns the layers that are not muted, or only the soloed ones if any layer is soloed
+func (l *Layered) activeLayers() []*Layer {
+	solo := false
+	for _, layer := range l.Layers {
+		solo = solo || layer.Solo
+	}
+	var active []*Layer
+	for _, layer := range l.Layers {
+		if !layer.Mute && (!solo || layer.Solo) {
+			active = append(active, layer)
+		}
+	}
+	return active
+}
+
+// render renders the layer with its gain, polarity and filters applied
+func (layer *Layer) render() ([][]float64, error) {
+	channels, err := layer.Settings.renderChannels()
+	if err != nil {
+		return nil, err
+	}
+	gain := math.Pow(10, layer.Gain/20)
+	if layer.Invert {
+		gain = -gain
+	}
+	for _, samples := range channels {
+		var highPass, lowPass *crossover
+		if layer.HighPass > 0 {
+			highPass = newCrossover(biquadHighPass, layer.HighPass, layer.Settings.SampleRate)
+		}
+		if layer.LowPass > 0 {
+			lowPass = newCrossover(biquadLowPass, layer.LowPass, layer.Settings.SampleRate)
+		}
+		for i, s := range samples {
+			if highPass != nil {
+				s = highPass.process(s)
+			}
+			if lowPass != nil {
+				s = lowPass.process(s)
+			}
+			samples[i] = s * gain
+		}
+	}
+	return channels, nil
+}
+
+// energy returns the sum of the squares of the samples
+func energy(samples []float64) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += s * s
+	}
+	return sum
+}
+
+// cancellation returns how much lower the energy of the sum of a and b delayed by the given number of
+// samples is, compared to the sum of their energies, in dB. Only the first length samples are compared.
+func cancellation(a, b []float64, delay int, sign float64, length int) float64 {
+	var sum, separate float64
+	for i := 0; i < length; i++ {
+		var x float64
+		if j := i - delay; j >= 0 && j < len(b) {
+			x = sign * b[j]
+		}
+		var y float64
+		if i < len(a) {
+			y = a[i]
+		}
+		sum += (x + y) * (x + y)
+		separate += x*x + y*y
+	}
+	if separate == 0 || sum == 0 {
+		return 0
+	}
+	return 10 * math.Log10(sum/separate)
+}
+
+// align finds the delay in samples, up to maxDelay, and the polarity that make the layer add up
+// best with the sum of the layers before it, starting at the given offset
+func align(sum, layer []float64, offset, maxDelay, length int) (int, float64) {
+	bestDelay, bestSign, bestCorrelation := 0, 1.0, math.Inf(-1)
+	for delay := 0; delay <= maxDelay; delay++ {
+		var correlation float64
+		for i := 0; i < length; i++ {
+			j := i - offset - delay
+			if j < 0 || j >= len(layer) || i >= len(sum) {
+				continue
+			}
+			correlation += sum[i] * layer[j]
+		}
+		for _, sign := range []float64{1, -1} {
+			if sign*correlation > bestCorrelation {
+				bestDelay, bestSign, bestCorrelation = delay, sign, sign*correlation
+			}
+		}
+	}
+	return bestDelay, bestSign
+}
+
+// Mix renders the active layers and mixes them, before the output stage.
+// The result is stereo if any of the layers is stereo.
+func (l *Layered) Mix() ([][]float64, error) {
+	layers := l.activeLayers()
+	if len(layers) == 0 {
+		return nil, errors.New("there are no active layers to mix")
+	}
+	sampleRate := l.Master.SampleRate
+
+	rendered := make([][][]float64, len(layers))
+	offsets := make([]int, len(layers))
+	numChannels, length := 1, 0
+	for i, layer := range layers {
+		if layer.Settings.SampleRate != sampleRate {
+			return nil, fmt.Errorf("layer %d has a sample rate of %d Hz, but the layered kick has %d Hz", i+1, layer.Settings.SampleRate, sampleRate)
+		}
+		channels, err := layer.render()
+		if err != nil {
+			return nil, err
+		}
+		rendered[i] = channels
+		offsets[i] = int(math.Max(0, layer.Offset) * float64(sampleRate))
+		numChannels = max(numChannels, len(channels))
+		length = max(length, offsets[i]+len(channels[0]))
+	}
+
+	// Add up the layers one at a time, so that each one can be lined up with the ones before it
+	maxDelay := int(math.Max(0, l.MaxShift) * float64(sampleRate))
+	window := min(length+maxDelay, int(alignmentWindow*float64(sampleRate)))
+	mono := make([]float64, length+maxDelay)
+	mixed := make([][]float64, numChannels)
+	for c := range mixed {
+		mixed[c] = make([]float64, length+maxDelay)
+	}
+	end := 0
+	for i, layer := range layers {
+		channels := rendered[i]
+		layerMono := mixDown(channels...)
+
+		shifted := make([]float64, offsets[i]+len(layerMono))
+		copy(shifted[offsets[i]:], layerMono)
+		alignment := LayerAlignment{Cancellation: cancellation(mono, shifted, 0, 1, window)}
+		alignment.Remaining = alignment.Cancellation
+		delay, sign := 0, 1.0
+		if l.AlignPhase && i > 0 {
+			delay, sign = align(mono, layerMono, offsets[i], maxDelay, window)
+			alignment.Shift = float64(delay) / float64(sampleRate)
+			alignment.Flipped = sign < 0
+			alignment.Remaining = cancellation(mono, shifted, delay, sign, window)
+		}
+		layer.Alignment = alignment
+
+		start := offsets[i] + delay
+		for j, s := range layerMono {
+			mono[start+j] += sign * s
+		}
+		layer.placed = make([][]float64, numChannels)
+		for c := range mixed {
+			layer.placed[c] = make([]float64, len(mixed[c]))
+			samples := channels[min(c, len(channels)-1)]
+			for j, s := range samples {
+				layer.placed[c][start+j] = sign * s
+				mixed[c][start+j] += sign * s
+			}
+		}
+		end = max(end, start+len(layerMono))
+	}
+
+	for c := range mixed {
+		mixed[c] = mixed[c][:end]
+	}
+	for _, layer := range layers {
+		for c := range layer.placed {
+			layer.placed[c] = layer.placed[c][:end]
+		}
+	}
+	return mixed, nil
+}
+
+// GenerateKick mixes the layers and writes the result to the Output of Master, through its output stage
+func (l *Layered) GenerateKick() error {
+	channels, err := l.Mix()
+	if err != nil {
+		return err
+	}
+	return l.Master.writeOutput(l.Master.applyOutputStage(channels...), len(channels))
+}
+
+// GenerateKickInMemory mixes the layers and returns the result from the output stage of Master, as signed samples.
+// For stereo output, the samples of the left and the right channel are interleaved.
+func (l *Layered) GenerateKickInMemory() ([]int, error) {
+	channels, err := l.Mix()
+	if err != nil {
+		return nil, err
+	}
+	return l.Master.applyOutputStage(channels...), nil
+}
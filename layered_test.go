@@ -0,0 +1,30 @@
+package kick
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// A layer that is a delayed and polarity-flipped copy of the sum should be moved back and flipped
+func TestAlignFlippedCopy(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	sum := make([]float64, 2000)
+	for i := range sum {
+		sum[i] = math.Sin(2*math.Pi*50*float64(i)/48000) + 0.2*(r.Float64()*2-1)
+	}
+	const shift = 7
+	layer := make([]float64, len(sum)-shift)
+	for i := range layer {
+		layer[i] = -sum[i+shift]
+	}
+	delay, sign := align(sum, layer, 0, 20, len(sum))
+	if delay != shift || sign != -1 {
+		t.Errorf("got a delay of %d samples and a sign of %g, want %d and -1", delay, sign, shift)
+	}
+
+	// An identical copy should be left as it is
+	if delay, sign := align(sum, sum, 0, 20, len(sum)); delay != 0 || sign != 1 {
+		t.Errorf("got a delay of %d samples and a sign of %g for an identical copy, want 0 and 1", delay, sign)
+	}
+}
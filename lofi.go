@@ -0,0 +1,88 @@
+package kick
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// LoFi describes a lo-fi signal path: an input filter, followed by sample rate and bit depth reduction
+type LoFi struct {
+	Bits        int     // bit depth to reduce to, 0 to keep the bit depth
+	Rate        float64 // sample rate to reduce to with sample-and-hold, in Hz, 0 to keep the sample rate
+	AntiAlias   bool    // low-pass filter just below half the reduced sample rate, before the rate reduction
+	InputFilter float64 // cutoff of the input low-pass filter in Hz, 0 for no input filter
+	InputSlope  int     // slope of the input filter in dB/octave: 12, 24, 36 or 48
+}
+
+// LoFiSamplers are emulations of the signal paths of vintage samplers
+var LoFiSamplers = map[string]LoFi{
+	// 12-bit at 26.04 kHz, with a gentle input filter that lets plenty of aliasing through
+	"sp1200": {Bits: 12, Rate: 26040, InputFilter: 13000, InputSlope: 12},
+	// 12-bit at 40 kHz, with a steep input filter for a smooth and warm sound
+	"mpc60": {Bits: 12, Rate: 40000, AntiAlias: true, InputFilter: 16000, InputSlope: 48},
+	// 12-bit at 39.375 kHz, with a steep input filter that is set a bit lower
+	"s950": {Bits: 12, Rate: 39375, AntiAlias: true, InputFilter: 14000, InputSlope: 48},
+	// 8-bit at 22.05 kHz, without filtering, as in early computer samplers
+	"8bit": {Bits: 8, Rate: 22050},
+}
+
+// LoFiSampler returns the named sampler emulation from LoFiSamplers
+func LoFiSampler(name string) (LoFi, error) {
+	lofi, ok := LoFiSamplers[strings.ToLower(name)]
+	if !ok {
+		var names []string
+		for name := range LoFiSamplers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return LoFi{}, fmt.Errorf("unknown sampler %q, choose from: %s", name, strings.Join(names, ", "))
+	}
+	return lofi, nil
+}
+
+// enabled returns true if the lo-fi signal path changes the signal
+func (lofi LoFi) enabled() bool {
+	return lofi.Bits > 0 || lofi.Rate > 0 || lofi.InputFilter > 0
+}
+
+// ApplyLoFi runs the given channels through the lo-fi signal path
+func ApplyLoFi(sampleRate int, lofi LoFi, channels ...[]float64) {
+	if !lofi.enabled() {
+		return
+	}
+
+	var bands []EQBand
+	if lofi.InputFilter > 0 {
+		bands = append(bands, EQBand{Type: EQLowPass, Freq: lofi.InputFilter, Q: math.Sqrt2 / 2, Slope: max(12, lofi.InputSlope)})
+	}
+	if lofi.AntiAlias && lofi.Rate > 0 {
+		bands = append(bands, EQBand{Type: EQLowPass, Freq: 0.45 * lofi.Rate, Q: math.Sqrt2 / 2, Slope: 48})
+	}
+	ApplyEQ(sampleRate, bands, channels...)
+
+	for _, ch := range channels {
+		// Sample-and-hold, where a new value is picked up every time the phase wraps around
+		if lofi.Rate > 0 && lofi.Rate < float64(sampleRate) {
+			step := lofi.Rate / float64(sampleRate)
+			phase := 1.0
+			var held float64
+			for i, x := range ch {
+				if phase >= 1 {
+					phase -= 1
+					held = x
+				}
+				phase += step
+				ch[i] = held
+			}
+		}
+
+		if lofi.Bits > 0 {
+			levels := float64(int(1) << (lofi.Bits - 1))
+			for i, x := range ch {
+				ch[i] = math.Round(x*levels) / levels
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package kick
+
+import (
+	"io"
+	"math"
+	"sort"
+)
+
+// Loudness holds loudness measurements of a signal, according to EBU R128 and ITU-R BS.1770
+type Loudness struct {
+	Integrated   float64 // gated integrated loudness, in LUFS
+	MomentaryMax float64 // largest momentary (400 ms) loudness, in LUFS
+	ShortTermMax float64 // largest short-term (3 s) loudness, in LUFS
+	Range        float64 // loudness range (LRA), in LU
+	TruePeak     float64 // true peak, in dBTP
+	Short        bool    // the signal is shorter than the 3 s short-term window, so ShortTermMax and Range are not meaningful
+}
+
+// MeasureLoudness measures the loudness of the given channels, with samples in the -1 to 1 range.
+// For signals shorter than 3 s, like most kicks, the short-term window is mostly silence, so ShortTermMax
+// understates the loudness and Range is 0. Short is set for those, and MomentaryMax should be used instead.
+func MeasureLoudness(sampleRate int, channels ...[]float64) Loudness {
+	weighted := kWeighted(sampleRate, channels...)
+
+	momentaryLength := int(0.4 * float64(sampleRate))
+	momentary := blockLoudness(weighted, momentaryLength, momentaryLength/4)
+
+	shortTermLength := 3 * sampleRate
+	shortTerm := blockLoudness(weighted, shortTermLength, sampleRate/10)
+
+	return Loudness{
+		Integrated:   gatedLoudness(momentary, -10),
+		MomentaryMax: maxLoudness(momentary),
+		ShortTermMax: maxLoudness(shortTerm),
+		Range:        loudnessRange(shortTerm),
+		TruePeak:     toDB(truePeak(channels...)),
+		Short:        len(channels) == 0 || len(channels[0]) < shortTermLength,
+	}
+}
+
+// MeasureLoudnessInts measures the loudness of samples of the given bit depth, as returned by
+// GenerateKickInMemory, where the samples of the channels are interleaved and signed, even at 8 bits
+func MeasureLoudnessInts(samples []int, sampleRate, bitDepth, numChannels int) Loudness {
+	return MeasureLoudness(sampleRate, deinterleave(toFloats(samples, bitDepth), numChannels)...)
+}
+
+// MeasureLoudnessWAV decodes a WAV file and measures its loudness
+func MeasureLoudnessWAV(r io.ReadSeeker) (Loudness, error) {
+	channels, sampleRate, _, err := ReadWAV(r)
+	if err != nil {
+		return Loudness{}, err
+	}
+	return MeasureLoudness(sampleRate, channels...), nil
+}
+
+// maxLoudness returns the largest of the given block loudness values
+func maxLoudness(blocks []float64) float64 {
+	loudest := -200.0
+	for _, l := range blocks {
+		loudest = math.Max(loudest, l)
+	}
+	return loudest
+}
+
+// loudnessRange returns the difference between the 10th and the 95th percentile of the short-term loudness
+// values that are above the absolute gate of -70 LUFS and the relative gate of 20 LU below their average,
+// as in EBU Tech 3342
+func loudnessRange(shortTerm []float64) float64 {
+	var absGated []float64
+	var sum float64
+	for _, l := range shortTerm {
+		if l > -70 {
+			absGated = append(absGated, l)
+			sum += math.Pow(10, l/10)
+		}
+	}
+	if len(absGated) == 0 {
+		return 0
+	}
+	threshold := 10*math.Log10(sum/float64(len(absGated))) - 20
+
+	var gated []float64
+	for _, l := range absGated {
+		if l > threshold {
+			gated = append(gated, l)
+		}
+	}
+	if len(gated) == 0 {
+		return 0
+	}
+	sort.Float64s(gated)
+	percentile := func(p float64) float64 {
+		return gated[int(math.Round(p*float64(len(gated)-1)))]
+	}
+	return percentile(0.95) - percentile(0.10)
+}
+
+// newKWeighting returns the two filter stages of the K-weighting from ITU-R BS.1770,
+// a high shelf that models the head, followed by a high-pass filter (the RLB weighting)
+func newKWeighting(sampleRate int) (*biquad, *biquad) {
+	fs := float64(sampleRate)
+
+	// Stage 1: high shelf, +4 dB above about 1.7 kHz
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := &biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	// Stage 2: high-pass at about 38 Hz
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highPass := &biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	return shelf, highPass
+}
+
+// kWeighted returns K-weighted copies of the given channels
+func kWeighted(sampleRate int, channels ...[]float64) [][]float64 {
+	weighted := make([][]float64, len(channels))
+	for c, ch := range channels {
+		shelf, highPass := newKWeighting(sampleRate)
+		weighted[c] = make([]float64, len(ch))
+		for i, x := range ch {
+			weighted[c][i] = highPass.process(shelf.process(x))
+		}
+	}
+	return weighted
+}
+
+// blockLoudness returns the loudness of blocks of the given length, that start every step samples.
+// A signal that is shorter than one block is measured as a single block, padded with silence.
+func blockLoudness(weighted [][]float64, length, step int) []float64 {
+	if len(weighted) == 0 {
+		return nil
+	}
+	numSamples := len(weighted[0])
+	if numSamples == 0 {
+		return nil
+	}
+	if step < 1 {
+		step = 1
+	}
+	var blocks []float64
+	for start := 0; start == 0 || start+length <= numSamples; start += step {
+		end := start + length
+		if end > numSamples {
+			end = numSamples
+		}
+		var power float64
+		for _, ch := range weighted {
+			var sum float64
+			for _, x := range ch[start:end] {
+				sum += x * x
+			}
+			power += sum / float64(length)
+		}
+		blocks = append(blocks, -0.691+10*math.Log10(power+1e-20))
+	}
+	return blocks
+}
+
+// integratedLoudness returns the gated integrated loudness of the given channels in LUFS, as in ITU-R BS.1770
+func integratedLoudness(sampleRate int, channels ...[]float64) float64 {
+	weighted := kWeighted(sampleRate, channels...)
+	length := int(0.4 * float64(sampleRate))
+	blocks := blockLoudness(weighted, length, length/4)
+	return gatedLoudness(blocks, -10)
+}
+
+// gatedLoudness averages the block loudness values above the absolute gate of -70 LUFS, and then
+// above a relative gate that is the given number of LU below that average
+func gatedLoudness(blocks []float64, relativeGate float64) float64 {
+	average := func(threshold float64) (float64, bool) {
+		var sum float64
+		n := 0
+		for _, l := range blocks {
+			if l > threshold {
+				sum += math.Pow(10, (l+0.691)/10)
+				n++
+			}
+		}
+		if n == 0 {
+			return -200, false
+		}
+		return -0.691 + 10*math.Log10(sum/float64(n)), true
+	}
+	ungated, ok := average(-70)
+	if !ok {
+		return -200
+	}
+	gated, _ := average(ungated + relativeGate)
+	return gated
+}
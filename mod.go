@@ -0,0 +1,339 @@
+package kick
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Modulation sources
+const (
+	ModSourceEnvelope = iota // an ADSR envelope over the sample, from 0 to 1
+	ModSourceLFO             // a low-frequency oscillator, from -1 to 1
+	ModSourceRandom          // random values from -1 to 1, held for one cycle each (sample-and-hold)
+	ModSourceVelocity        // the velocity, from 0 to 1
+)
+
+// Modulation destinations
+const (
+	ModDrive           = iota // added to Drive
+	ModCutoff                 // in octaves, added to the cutoff of the tone filter
+	ModResonance              // added to FilterResonance
+	ModOscillatorLevel        // relative change of the level of an oscillator, like 0.5 for +50%
+	ModNoiseAmount            // relative change of NoiseAmount
+	ModPan                    // pan from -1 (left) to 1 (right), which makes the output stereo
+)
+
+// ModSource is a modulation source. Only the fields for its Type are used.
+type ModSource struct {
+	Type    int
+	Attack  float64 // envelope attack time, in seconds
+	Decay   float64 // envelope decay time, in seconds
+	Sustain float64 // envelope sustain level, from 0 to 1
+	Release float64 // envelope release time, in seconds
+	Shape   int     // LFO waveform, one of WaveSine, WaveTriangle, WaveSawtooth or WaveSquare
+	Rate    float64 // LFO or sample-and-hold rate, in Hz
+	Sync    float64 // length of a cycle in beats at Tempo, like 0.25 for 16th notes, used instead of Rate if above 0
+	Phase   float64 // LFO start phase, in degrees
+	Seed    int64   // seed for the random values
+}
+
+// ModRoute sends a modulation source to a destination
+type ModRoute struct {
+	Source      int     // index into ModSources
+	Destination int     // one of the Mod destination constants, like ModCutoff
+	Depth       float64 // how much the source changes the destination, in the unit of the destination
+	Oscillator  int     // for ModOscillatorLevel, the oscillator to modulate, counting from 1, or 0 for all of them
+}
+
+// modulator computes the modulation sources of cfg over time
+type modulator struct {
+	cfg    *Settings
+	values []float64
+	random []*rand.Rand
+	cycle  []int
+}
+
+// newModulator returns a modulator for the sources in cfg, or nil if there are no routes
+func (cfg *Settings) newModulator() *modulator {
+	if len(cfg.ModRoutes) == 0 {
+		return nil
+	}
+	m := &modulator{
+		cfg:    cfg,
+		values: make([]float64, len(cfg.ModSources)),
+		random: make([]*rand.Rand, len(cfg.ModSources)),
+		cycle:  make([]int, len(cfg.ModSources)),
+	}
+	for i, source := range cfg.ModSources {
+		if source.Type == ModSourceRandom {
+			m.random[i] = rand.New(rand.NewSource(source.Seed))
+			m.cycle[i] = -1
+		}
+	}
+	return m
+}
+
+// rate returns the rate of the given source in Hz, synced to the tempo if Sync is set
+func (cfg *Settings) rate(source ModSource) float64 {
+	if source.Sync > 0 && cfg.Tempo > 0 {
+		return cfg.Tempo / 60 / source.Sync
+	}
+	return source.Rate
+}
+
+// update computes the value of each source at the given time, in seconds
+func (m *modulator) update(t float64) {
+	if m == nil {
+		return
+	}
+	cfg := m.cfg
+	for i, source := range cfg.ModSources {
+		switch source.Type {
+		case ModSourceEnvelope:
+			m.values[i] = applyEnvelope(t, source.Attack, source.Decay, source.Sustain, source.Release, cfg.Duration)
+		case ModSourceLFO:
+			m.values[i] = periodicWave(source.Shape, t*cfg.rate(source)+source.Phase/360)
+		case ModSourceRandom:
+			if cycle := int(t * cfg.rate(source)); cycle != m.cycle[i] {
+				m.cycle[i] = cycle
+				m.values[i] = m.random[i].Float64()*2 - 1
+			}
+		case ModSourceVelocity:
+			m.values[i] = cfg.velocity()
+		}
+	}
+}
+
+// offset returns the sum of the modulation that is routed to the given destination, where oscillator
+// counts from 1 for ModOscillatorLevel, and is 0 otherwise
+func (m *modulator) offset(destination, oscillator int) float64 {
+	if m == nil {
+		return 0
+	}
+	var sum float64
+	for _, route := range m.cfg.ModRoutes {
+		if route.Destination != destination || route.Source < 0 || route.Source >= len(m.values) {
+			continue
+		}
+		if destination == ModOscillatorLevel && route.Oscillator != 0 && route.Oscillator != oscillator {
+			continue
+		}
+		sum += route.Depth * m.values[route.Source]
+	}
+	return sum
+}
+
+// hasModRoute returns true if any modulation is routed to the given destination
+func (cfg *Settings) hasModRoute(destination int) bool {
+	for _, route := range cfg.ModRoutes {
+		if route.Destination == destination {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckModulation returns an error if a route refers to a source that does not exist, or if the filter
+// cutoff or resonance is modulated while FilterModel is FilterNone, where the route would do nothing
+func (cfg *Settings) CheckModulation() error {
+	for _, route := range cfg.ModRoutes {
+		if route.Source < 0 || route.Source >= len(cfg.ModSources) {
+			return fmt.Errorf("there is no modulation source %d", route.Source+1)
+		}
+		if (route.Destination == ModCutoff || route.Destination == ModResonance) && cfg.FilterModel == FilterNone {
+			return errors.New("the cutoff and the resonance can only be modulated with a filter model")
+		}
+	}
+	return nil
+}
+
+// applyModPan pans the channels with the modulation that is routed to ModPan, with a constant power pan law.
+// Mono input is made stereo first.
+func (cfg *Settings) applyModPan(channels [][]float64) [][]float64 {
+	if !cfg.hasModRoute(ModPan) {
+		return channels
+	}
+	if len(channels) == 1 {
+		channels = append(channels, append([]float64(nil), channels[0]...))
+	}
+	m := cfg.newModulator()
+	for i := range channels[0] {
+		m.update(float64(i) / float64(cfg.SampleRate))
+		angle := (math.Max(-1, math.Min(1, m.offset(ModPan, 0))) + 1) * math.Pi / 4
+		channels[0][i] *= math.Cos(angle) * math.Sqrt2
+		channels[1][i] *= math.Sin(angle) * math.Sqrt2
+	}
+	return channels
+}
+
+var modDestinationNames = map[string]int{
+	"drive":     ModDrive,
+	"cutoff":    ModCutoff,
+	"resonance": ModResonance,
+	"level":     ModOscillatorLevel,
+	"noise":     ModNoiseAmount,
+	"pan":       ModPan,
+}
+
+var lfoShapeNames = map[string]int{
+	"sine":     WaveSine,
+	"triangle": WaveTriangle,
+	"saw":      WaveSawtooth,
+	"square":   WaveSquare,
+}
+
+// parseModRate parses a rate in Hz, like 8, or a note length that is synced to the tempo, like 1/16.
+// It returns the rate and the sync, in beats.
+func parseModRate(s string) (float64, float64, error) {
+	if numerator, denominator, ok := strings.Cut(s, "/"); ok {
+		n, err := strconv.ParseFloat(numerator, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		d, err := strconv.ParseFloat(denominator, 64)
+		if err != nil || d == 0 {
+			return 0, 0, fmt.Errorf("invalid note length %q", s)
+		}
+		return 0, 4 * n / d, nil
+	}
+	rate, err := strconv.ParseFloat(s, 64)
+	return rate, 0, err
+}
+
+// ParseModulation parses comma-separated modulation routes, written as source>destination:depth, with the sources
+//
+//	env:attack:decay[:sustain[:release]]  an envelope, with times in seconds
+//	lfo:shape:rate[:phase]                an LFO (sine, triangle, saw or square), with the rate in Hz or as a note length like 1/16
+//	random:rate                           sample-and-hold random values, with the rate in Hz or as a note length
+//	velocity                              the velocity
+//
+// and the destinations drive, cutoff, resonance, level (or level1, level2 and so on for a single oscillator), noise and pan.
+// For example: "lfo:sine:1/16>cutoff:1,env:0:0.05>drive:0.5". Each route gets a source of its own, unless the source
+// is given a name, like "wobble=lfo:sine:1/16>cutoff:1", and later routes refer to it by that name, like "wobble>drive:0.3",
+// or by its number, counting from 1, like "#1>drive:0.3".
+func ParseModulation(input string) ([]ModSource, []ModRoute, error) {
+	var sources []ModSource
+	var routes []ModRoute
+	names := make(map[string]int)
+	for _, field := range strings.Split(input, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		sourceText, destinationText, ok := strings.Cut(field, ">")
+		if !ok {
+			return nil, nil, fmt.Errorf("missing > between the source and the destination in %q", field)
+		}
+		route := ModRoute{Source: len(sources)}
+
+		// Refer to an earlier source, by number or by name
+		shared := true
+		if number, ok := strings.CutPrefix(sourceText, "#"); ok {
+			n, err := strconv.Atoi(number)
+			if err != nil || n < 1 || n > len(sources) {
+				return nil, nil, fmt.Errorf("there is no modulation source %q in %q", sourceText, field)
+			}
+			route.Source = n - 1
+		} else if i, ok := names[strings.ToLower(sourceText)]; ok {
+			route.Source = i
+		} else {
+			shared = false
+		}
+
+		// Name a new source
+		if name, definition, ok := strings.Cut(sourceText, "="); ok && !shared {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" || strings.ContainsAny(name, ":#") {
+				return nil, nil, fmt.Errorf("invalid modulation source name %q in %q", name, field)
+			}
+			if _, exists := names[name]; exists {
+				return nil, nil, fmt.Errorf("the modulation source %q is named twice in %q", name, field)
+			}
+			names[name] = len(sources)
+			sourceText = definition
+		}
+
+		parts := strings.Split(sourceText, ":")
+		values := make([]float64, 0, len(parts))
+		source := ModSource{}
+		var err error
+		switch kind := strings.ToLower(parts[0]); {
+		case shared:
+		case kind == "env":
+			source.Type = ModSourceEnvelope
+			for _, part := range parts[1:] {
+				v, err := strconv.ParseFloat(part, 64)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid envelope value %q in %q", part, field)
+				}
+				values = append(values, v)
+			}
+			if len(values) < 2 {
+				return nil, nil, fmt.Errorf("an envelope needs an attack and a decay time in %q", field)
+			}
+			source.Attack, source.Decay = values[0], values[1]
+			if len(values) > 2 {
+				source.Sustain = values[2]
+			}
+			if len(values) > 3 {
+				source.Release = values[3]
+			}
+		case kind == "lfo":
+			source.Type = ModSourceLFO
+			if len(parts) < 3 {
+				return nil, nil, fmt.Errorf("an LFO needs a shape and a rate in %q", field)
+			}
+			if source.Shape, ok = lfoShapeNames[strings.ToLower(parts[1])]; !ok {
+				return nil, nil, fmt.Errorf("unknown LFO shape %q in %q, choose from: sine, triangle, saw, square", parts[1], field)
+			}
+			if source.Rate, source.Sync, err = parseModRate(parts[2]); err != nil {
+				return nil, nil, fmt.Errorf("invalid LFO rate %q in %q", parts[2], field)
+			}
+			if len(parts) > 3 {
+				if source.Phase, err = strconv.ParseFloat(parts[3], 64); err != nil {
+					return nil, nil, fmt.Errorf("invalid LFO phase %q in %q", parts[3], field)
+				}
+			}
+		case kind == "random":
+			source.Type = ModSourceRandom
+			if len(parts) < 2 {
+				return nil, nil, fmt.Errorf("a random source needs a rate in %q", field)
+			}
+			if source.Rate, source.Sync, err = parseModRate(parts[1]); err != nil {
+				return nil, nil, fmt.Errorf("invalid random rate %q in %q", parts[1], field)
+			}
+			source.Seed = int64(len(sources) + 1)
+		case kind == "velocity":
+			source.Type = ModSourceVelocity
+		default:
+			return nil, nil, fmt.Errorf("unknown modulation source %q in %q", parts[0], field)
+		}
+
+		name, depthText, ok := strings.Cut(destinationText, ":")
+		if !ok {
+			return nil, nil, fmt.Errorf("missing depth in %q", field)
+		}
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "level") && name != "level" {
+			if route.Oscillator, err = strconv.Atoi(strings.TrimPrefix(name, "level")); err != nil || route.Oscillator < 1 {
+				return nil, nil, fmt.Errorf("invalid oscillator in %q", field)
+			}
+			name = "level"
+		}
+		if route.Destination, ok = modDestinationNames[name]; !ok {
+			return nil, nil, fmt.Errorf("unknown modulation destination %q in %q", name, field)
+		}
+		if route.Depth, err = strconv.ParseFloat(depthText, 64); err != nil {
+			return nil, nil, fmt.Errorf("invalid depth %q in %q", depthText, field)
+		}
+		if !shared {
+			sources = append(sources, source)
+		}
+		routes = append(routes, route)
+	}
+	return sources, routes, nil
+}
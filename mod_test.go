@@ -0,0 +1,41 @@
+package kick
+
+import "testing"
+
+func TestParseModulationSharedSources(t *testing.T) {
+	sources, routes, err := ParseModulation("sh=random:1/32>pan:0.5,env:0:0.05>drive:0.8,SH>cutoff:1,#2>level2:0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 2 || len(routes) != 4 {
+		t.Fatalf("got %d sources and %d routes, want 2 and 4", len(sources), len(routes))
+	}
+	for i, want := range []int{0, 1, 0, 1} {
+		if routes[i].Source != want {
+			t.Errorf("route %d uses source %d, want %d", i+1, routes[i].Source, want)
+		}
+	}
+	if sources[0].Type != ModSourceRandom || sources[1].Type != ModSourceEnvelope {
+		t.Errorf("wrong source types %d and %d", sources[0].Type, sources[1].Type)
+	}
+
+	for _, input := range []string{"#1>drive:1", "sh>drive:1", "a=env:0:1>drive:1,a=env:0:1>drive:1", "=env:0:1>drive:1"} {
+		if _, _, err := ParseModulation(input); err == nil {
+			t.Errorf("ParseModulation(%q) did not return an error", input)
+		}
+	}
+
+	cfg, err := New909(48000, 0.3, 16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ModSources, cfg.ModRoutes = sources, routes
+	cfg.FilterModel = FilterNone
+	if cfg.CheckModulation() == nil {
+		t.Error("modulating the cutoff without a filter model did not return an error")
+	}
+	cfg.FilterModel = FilterLadder
+	if err := cfg.CheckModulation(); err != nil {
+		t.Error(err)
+	}
+}
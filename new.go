@@ -88,6 +88,11 @@ func NewDeepHouse(sampleRate int, duration float64, bitDepth int, output io.Writ
 	cfg.PitchDecay = 0.6                  // Slight pitch decay for that deep house feel
 	cfg.FadeDuration = 0.03               // 30ms fade in/out for a more gradual sound
 	cfg.SmoothFrequencyTransitions = true // Enable smooth frequency transitions
+	cfg.EQ = []EQBand{
+		{Type: EQHighPass, Freq: 15, Q: 0.707, Slope: 24}, // Remove rumble below the fundamental
+		{Type: EQLowShelf, Freq: 60, Gain: 2, Q: 0.707},   // Extra weight in the sub
+		{Type: EQPeak, Freq: 300, Gain: -3, Q: 1.5},       // Less boxiness
+	}
 
 	return cfg, nil
 }
@@ -168,6 +173,10 @@ func New909(sampleRate int, duration float64, bitDepth int, output io.WriteSeeke
 	cfg.PitchDecay = 0.2
 	cfg.FadeDuration = 0.015              // 15ms fade in/out to balance between smoothness and clarity
 	cfg.SmoothFrequencyTransitions = true // Enable smooth frequency transitions
+	cfg.EQ = []EQBand{
+		{Type: EQPeak, Freq: 60, Gain: 2, Q: 1.4}, // Body
+		{Type: EQPeak, Freq: 4000, Gain: 2, Q: 1}, // Presence for the beater click
+	}
 
 	return cfg, nil
 }
@@ -0,0 +1,107 @@
+package kick
+
+import (
+	"math"
+	"math/rand"
+)
+
+const (
+	NoiseFilterNone = iota
+	NoiseFilterHighPass
+	NoiseFilterBandPass
+)
+
+// noiseLayer is a noise source with its own envelope, filter, start offset and level
+type noiseLayer struct {
+	cfg    *Settings
+	filter *biquad
+	source *noiseSource
+	start  int
+}
+
+func (cfg *Settings) newNoiseLayer(channel int) *noiseLayer {
+	n := &noiseLayer{
+		cfg:    cfg,
+		source: cfg.newNoiseSource(channel, 1),
+		start:  int(cfg.NoiseDelay * float64(cfg.SampleRate)),
+	}
+	switch cfg.NoiseFilter {
+	case NoiseFilterHighPass:
+		n.filter = newBiquad(biquadHighPass, cfg.NoiseFilterFreq, cfg.NoiseFilterQ, cfg.SampleRate)
+	case NoiseFilterBandPass:
+		n.filter = newBiquad(biquadBandPass, cfg.NoiseFilterFreq, cfg.NoiseFilterQ, cfg.SampleRate)
+	}
+	return n
+}
+
+// sample returns the noise layer sample for the given sample index
+func (n *noiseLayer) sample(i int) float64 {
+	cfg := n.cfg
+	if cfg.NoiseType == NoiseNone || cfg.NoiseAmount == 0 || i < n.start {
+		return 0
+	}
+	j := i - n.start
+
+	var noiseSample float64
+	switch cfg.NoiseType {
+	case NoiseWhite:
+		noiseSample = n.source.white()
+	case NoisePink:
+		noiseSample = n.source.pink(j)
+	case NoiseBrown:
+		noiseSample = n.source.brown()
+	}
+
+	if n.filter != nil {
+		noiseSample = n.filter.process(noiseSample)
+	}
+
+	t := float64(j) / float64(cfg.SampleRate)
+	return noiseSample * noiseEnvelope(t, cfg.NoiseAttack, cfg.NoiseDecay) * cfg.NoiseAmount
+}
+
+// noiseEnvelope is a linear attack followed by an exponential decay that reaches 1% after the decay time.
+// A decay of 0 keeps the noise at full level after the attack.
+func noiseEnvelope(t, attack, decay float64) float64 {
+	if t < attack {
+		return t / attack
+	}
+	if decay <= 0 {
+		return 1.0
+	}
+	return math.Exp(-4.6 * (t - attack) / decay)
+}
+
+// noiseSource generates white, pink and brown noise from its own random source, so that
+// renders with the same NoiseSeed get the same noise
+type noiseSource struct {
+	random                            *rand.Rand
+	pinkAccumulator, brownAccumulator float64
+}
+
+// newNoiseSource returns a noise source for the given channel (0 for mono or mid, 1 and 2 for
+// left and right) and the given generator (0 for the oscillators, 1 for the noise layer), each
+// seeded differently from NoiseSeed, so that the stereo channels get independent noise
+func (cfg *Settings) newNoiseSource(channel, generator int) *noiseSource {
+	seed := cfg.NoiseSeed*6 + int64(channel*2+generator)
+	return &noiseSource{random: rand.New(rand.NewSource(seed))}
+}
+
+func (n *noiseSource) white() float64 {
+	return n.random.Float64()*2 - 1
+}
+
+func (n *noiseSource) pink(i int) float64 {
+	n.pinkAccumulator += n.white()
+	return n.pinkAccumulator / float64(i+1)
+}
+
+func (n *noiseSource) brown() float64 {
+	n.brownAccumulator += n.white() * 0.1
+	if n.brownAccumulator > 1 {
+		n.brownAccumulator = 1
+	} else if n.brownAccumulator < -1 {
+		n.brownAccumulator = -1
+	}
+	return n.brownAccumulator
+}
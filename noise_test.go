@@ -0,0 +1,34 @@
+package kick
+
+import "testing"
+
+// Renders with the same NoiseSeed should be identical, so that stems line up with the mix
+func TestNoiseSeed(t *testing.T) {
+	render := func(seed int64) []float64 {
+		cfg, err := New909(48000, 0.3, 16, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.NoiseType = NoisePink
+		cfg.NoiseAmount = 0.3
+		cfg.NoiseSeed = seed
+		buffer, err := cfg.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buffer.Channels[0]
+	}
+	a, b, c := render(1), render(1), render(2)
+	same := true
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("sample %d differs between two renders with the same seed", i)
+		}
+		if a[i] != c[i] {
+			same = false
+		}
+	}
+	if same {
+		t.Error("renders with different seeds are identical")
+	}
+}
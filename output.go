@@ -0,0 +1,247 @@
+package kick
+
+import "math"
+
+// oversampling is the oversampling factor used when looking for inter-sample peaks
+const oversampling = 4
+
+// interpolationTaps is the number of taps on each side of the interpolation filter
+const interpolationTaps = 6
+
+// interpolationKernel holds a Hann windowed sinc filter for each fractional position between two samples
+var interpolationKernel = func() [oversampling][2 * interpolationTaps]float64 {
+	var kernel [oversampling][2 * interpolationTaps]float64
+	for phase := 1; phase < oversampling; phase++ {
+		frac := float64(phase) / oversampling
+		var sum float64
+		for k := -interpolationTaps + 1; k <= interpolationTaps; k++ {
+			x := frac - float64(k)
+			window := 0.5 + 0.5*math.Cos(math.Pi*x/interpolationTaps)
+			v := window * math.Sin(math.Pi*x) / (math.Pi * x)
+			kernel[phase][k+interpolationTaps-1] = v
+			sum += v
+		}
+		for i := range kernel[phase] {
+			kernel[phase][i] /= sum
+		}
+	}
+	return kernel
+}()
+
+// interSamplePeaks returns, for every sample, the largest magnitude of the sample itself and of the
+// interpolated signal between it and the next sample, for the loudest of the given channels
+func interSamplePeaks(channels ...[]float64) []float64 {
+	if len(channels) == 0 {
+		return nil
+	}
+	numSamples := len(channels[0])
+	peaks := make([]float64, numSamples)
+	for _, ch := range channels {
+		for i := 0; i < numSamples; i++ {
+			peak := math.Abs(ch[i])
+			for phase := 1; phase < oversampling; phase++ {
+				var v float64
+				for k := -interpolationTaps + 1; k <= interpolationTaps; k++ {
+					if j := i + k; j >= 0 && j < numSamples {
+						v += ch[j] * interpolationKernel[phase][k+interpolationTaps-1]
+					}
+				}
+				peak = math.Max(peak, math.Abs(v))
+			}
+			peaks[i] = math.Max(peaks[i], peak)
+		}
+	}
+	return peaks
+}
+
+// truePeak returns the largest inter-sample peak of the given channels, as a linear value
+func truePeak(channels ...[]float64) float64 {
+	var peak float64
+	for _, p := range interSamplePeaks(channels...) {
+		peak = math.Max(peak, p)
+	}
+	return peak
+}
+
+// toDB converts a linear value to dB, with a floor at -200 dB for silence
+func toDB(value float64) float64 {
+	if value < 1e-10 {
+		return -200
+	}
+	return 20 * math.Log10(value)
+}
+
+// applyGain multiplies the given channels by the given gain in dB
+func applyGain(gainDB float64, channels ...[]float64) {
+	gain := math.Pow(10, gainDB/20)
+	for _, ch := range channels {
+		for i := range ch {
+			ch[i] *= gain
+		}
+	}
+}
+
+// applyLimiter is a lookahead true-peak limiter, that keeps the inter-sample peaks of the given channels
+// at or below the ceiling. The gain reduction ramps down over the lookahead time, so that it is fully
+// in place when a peak arrives, and recovers with the given release time. The largest reduction in dB is returned.
+func applyLimiter(ceiling, lookahead, release float64, sampleRate int, channels ...[]float64) float64 {
+	if len(channels) == 0 || len(channels[0]) == 0 {
+		return 0
+	}
+	numSamples := len(channels[0])
+	window := int(lookahead * float64(sampleRate))
+	if window < 1 {
+		window = 1
+	}
+
+	// The gain that each sample needs in order to stay below the ceiling
+	required := interSamplePeaks(channels...)
+	for i, peak := range required {
+		if peak > ceiling {
+			required[i] = ceiling / peak
+		} else {
+			required[i] = 1
+		}
+	}
+
+	// Look ahead for the lowest required gain, and release slowly afterwards
+	releaseCoeff := timeConstant(release, sampleRate)
+	held := make([]float64, numSamples)
+	gain := 1.0
+	for i := 0; i < numSamples; i++ {
+		target := 1.0
+		for j := i; j <= i+window && j < numSamples; j++ {
+			target = math.Min(target, required[j])
+		}
+		if target < gain {
+			gain = target
+		} else {
+			gain = releaseCoeff*gain + (1-releaseCoeff)*target
+		}
+		held[i] = gain
+	}
+
+	// Smooth the gain with a moving average over the lookahead window, which never rises above the held gain
+	// of the upcoming peak, since all the averaged values have already seen it
+	var sum, maxReduction float64
+	for i := 0; i < numSamples; i++ {
+		sum += held[i]
+		if i > window {
+			sum -= held[i-window-1]
+		}
+		n := math.Min(float64(i+1), float64(window+1))
+		g := sum / n
+		for _, ch := range channels {
+			ch[i] *= g
+		}
+		maxReduction = math.Max(maxReduction, -toDB(g))
+	}
+
+	// The interpolated peaks can still end up marginally above the ceiling, so trim what is left
+	if peak := truePeak(channels...); peak > ceiling {
+		applyGain(toDB(ceiling/peak), channels...)
+		maxReduction += -toDB(ceiling / peak)
+	}
+
+	return maxReduction
+}
+
+// quantize converts samples in the -1 to 1 range to integers of the given bit depth.
+// Samples outside of the range are clipped, and the number of clipped samples is returned.
+func quantize(samples []float64, bitDepth int) ([]int, int) {
+	fullScale := float64(int(1) << (bitDepth - 1))
+	ints := make([]int, len(samples))
+	clipped := 0
+	for i, sample := range samples {
+		v := math.Round(sample * fullScale)
+		if v > fullScale-1 {
+			v = fullScale - 1
+			clipped++
+		} else if v < -fullScale {
+			v = -fullScale
+			clipped++
+		}
+		ints[i] = int(v)
+	}
+	return ints, clipped
+}
+
+// finishChannels brings the channels to the target peak or loudness, applies the velocity gain, limits the
+// true peak to TargetPeak and trims the tail. The levels are recorded in cfg.Stats.
+func (cfg *Settings) finishChannels(channels ...[]float64) [][]float64 {
+	var peak float64
+	clipped := 0
+	for _, samples := range channels {
+		for _, sample := range samples {
+			peak = math.Max(peak, math.Abs(sample))
+			if math.Abs(sample) > 1 {
+				clipped++
+			}
+		}
+	}
+	cfg.Stats.InputPeak = toDB(peak)
+	cfg.Stats.ClippedSamples = clipped
+
+	cfg.Stats.OutputGain = 0
+	if cfg.Normalize && peak > 0 {
+		if cfg.TargetLoudness < 0 {
+			cfg.Stats.OutputGain = cfg.TargetLoudness - integratedLoudness(cfg.SampleRate, channels...)
+		} else {
+			cfg.Stats.OutputGain = cfg.TargetPeak - toDB(truePeak(channels...))
+		}
+		applyGain(cfg.Stats.OutputGain, channels...)
+	}
+
+	cfg.Stats.LimiterReduction = 0
+	if cfg.Limiter {
+		cfg.Stats.LimiterReduction = applyLimiter(math.Pow(10, cfg.TargetPeak/20), cfg.LimiterLookahead, cfg.LimiterRelease, cfg.SampleRate, channels...)
+	}
+
+	// The velocity gain comes after the normalization, so that soft hits stay softer than hard ones
+	if gain := cfg.velocityGain(); gain < 1 {
+		applyGain(toDB(gain), channels...)
+	}
+
+	channels = cfg.applyTrim(channels)
+
+	cfg.Stats.TruePeak = toDB(truePeak(channels...))
+
+	cfg.Stats.Correlation = StereoCorrelation{Correlation: 1, MinCorrelation: 1}
+	if len(channels) == 2 {
+		cfg.Stats.Correlation = MeasureCorrelation(cfg.SampleRate, channels[0], channels[1])
+	}
+
+	cfg.Stats.ClippedOutput = 0
+	for _, samples := range channels {
+		for _, sample := range samples {
+			if math.Abs(sample) > 1 {
+				cfg.Stats.ClippedOutput++
+			}
+		}
+	}
+	return channels
+}
+
+// applyOutputStage runs the output stage with finishChannels, and converts the samples to interleaved
+// integers of the configured bit depth
+func (cfg *Settings) applyOutputStage(channels ...[]float64) []int {
+	channels = cfg.finishChannels(channels...)
+	ints, clippedOutput := quantize(interleave(channels...), cfg.BitDepth)
+	cfg.Stats.ClippedOutput = clippedOutput
+	return ints
+}
+
+// interleave combines the given channels into a single slice, frame by frame
+func interleave(channels ...[]float64) []float64 {
+	if len(channels) == 1 {
+		return channels[0]
+	}
+	numChannels := len(channels)
+	interleaved := make([]float64, len(channels[0])*numChannels)
+	for c, ch := range channels {
+		for i, sample := range ch {
+			interleaved[i*numChannels+c] = sample
+		}
+	}
+	return interleaved
+}
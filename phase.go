@@ -0,0 +1,91 @@
+package kick
+
+import "math"
+
+const (
+	AutoPhaseNone    = iota // use StartPhase as it is
+	AutoPhasePunch          // pick the start phase that gives the largest initial transient
+	AutoPhaseNoClick        // pick the start phase that starts closest to zero, on a rising slope
+)
+
+// periodicWave returns the value of a periodic waveform after the given number of cycles.
+// For the noise waveforms, 0 is returned.
+func periodicWave(waveformType int, cycles float64) float64 {
+	switch waveformType {
+	case WaveSine:
+		return math.Sin(2 * math.Pi * cycles)
+	case WaveTriangle:
+		return 2*math.Abs(2*(cycles-math.Floor(cycles+0.5))) - 1
+	case WaveSawtooth:
+		return 2 * (cycles - math.Floor(0.5+cycles))
+	case WaveSquare:
+		return math.Copysign(1.0, math.Sin(2*math.Pi*cycles))
+	}
+	return 0
+}
+
+// oscillatorPhase returns the start phase of the given oscillator relative to StartPhase, in degrees
+func (cfg *Settings) oscillatorPhase(oscIndex int) float64 {
+	if oscIndex < len(cfg.OscillatorPhases) {
+		return cfg.OscillatorPhases[oscIndex]
+	}
+	return 0
+}
+
+// startValue returns the sum of the oscillators at the very start, for the given start phase in degrees
+func (cfg *Settings) startValue(phase float64) float64 {
+	var sum float64
+	for oscIndex := 0; oscIndex < cfg.NumOscillators; oscIndex++ {
+		sum += cfg.OscillatorLevels[oscIndex] * periodicWave(cfg.WaveformType, (phase+cfg.oscillatorPhase(oscIndex))/360)
+	}
+	return sum
+}
+
+// startPhase returns the start phase of the main body in degrees, which is either StartPhase
+// or the phase that is picked by AutoPhase, plus PhaseOffset
+func (cfg *Settings) startPhase() float64 {
+	return math.Mod(cfg.basePhase()+cfg.PhaseOffset+360, 360)
+}
+
+// basePhase returns StartPhase, or the phase that is picked by AutoPhase, in degrees
+func (cfg *Settings) basePhase() float64 {
+	if cfg.AutoPhase == AutoPhaseNone || cfg.WaveformType > WaveSquare {
+		return math.Mod(cfg.StartPhase, 360)
+	}
+	best, bestScore := 0.0, math.Inf(-1)
+	for phase := 0.0; phase < 360; phase++ {
+		value := cfg.startValue(phase)
+		var score float64
+		if cfg.AutoPhase == AutoPhasePunch {
+			score = math.Abs(value)
+		} else {
+			score = -math.Abs(value)
+			if cfg.startValue(phase+1) < value {
+				// Prefer a rising start, so that the kick pushes the speaker out first
+				score -= 1
+			}
+		}
+		if score > bestScore {
+			best, bestScore = phase, score
+		}
+	}
+	return best
+}
+
+// hasStartPhase returns true if the oscillators are set to start at a phase other than 0,
+// not counting the small PhaseOffset of round-robin variants,
+// in which case the start of the sample is not faded in, and the envelope skips the attack ramp
+func (cfg *Settings) hasStartPhase() bool {
+	if cfg.startPhaseSet != nil {
+		return *cfg.startPhaseSet
+	}
+	if cfg.AutoPhase == AutoPhasePunch || math.Mod(cfg.StartPhase, 360) != 0 {
+		return true
+	}
+	for _, phase := range cfg.OscillatorPhases {
+		if math.Mod(phase, 360) != 0 {
+			return true
+		}
+	}
+	return false
+}
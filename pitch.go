@@ -0,0 +1,173 @@
+package kick
+
+import (
+	"io"
+	"math"
+	"sort"
+)
+
+const (
+	pitchAnalysisRate = 8000   // sample rate that the signal is downsampled to before the pitch is detected
+	pitchMinFreq      = 20.0   // lowest fundamental that is detected, in Hz
+	pitchMaxFreq      = 1000.0 // highest fundamental that is detected, in Hz
+	pitchHop          = 0.01   // time between pitch measurements, in seconds
+	pitchThreshold    = 0.15   // YIN threshold for the cumulative mean normalized difference
+	pitchSilence      = -60.0  // frames this many dB below the loudest frame are not analyzed
+)
+
+// PitchPoint is a single measurement of the fundamental of a signal
+type PitchPoint struct {
+	Time      float64 // center of the analysis window, in seconds
+	Frequency float64 // fundamental, in Hz
+	Clarity   float64 // how periodic the window is, from 0 to 1
+}
+
+// Pitch holds the detected fundamental of a kick
+type Pitch struct {
+	Frequency  float64      // fundamental of the tail, in Hz, or 0 if no pitch was found
+	Note       int          // MIDI note number nearest to the fundamental of the tail, with A4 at 440 Hz
+	Cents      float64      // offset of the fundamental of the tail from Note, in cents
+	Trajectory []PitchPoint // the fundamental over time, for the windows where a pitch was found
+}
+
+// DetectPitch detects the fundamental of the given channels over time with the YIN algorithm,
+// and the fundamental of the tail, which is the median of the last quarter of the pitched part.
+// Use FrequencyToNote for a different frequency of A4.
+func DetectPitch(sampleRate int, channels ...[]float64) Pitch {
+	if len(channels) == 0 || len(channels[0]) == 0 {
+		return Pitch{}
+	}
+	mono := mixDown(channels...)
+	rate := sampleRate
+	if rate > pitchAnalysisRate {
+		mono = resample(mono, sampleRate, pitchAnalysisRate)
+		rate = pitchAnalysisRate
+	}
+
+	maxLag := int(float64(rate) / pitchMinFreq)
+	minLag := max(2, int(float64(rate)/pitchMaxFreq))
+	window := maxLag
+	hop := max(1, int(pitchHop*float64(rate)))
+
+	// Find the level of the loudest window, to skip the silent ones
+	var loudest float64
+	for start := 0; start+window <= len(mono); start += hop {
+		loudest = math.Max(loudest, rms(mono[start:start+window]))
+	}
+	if loudest == 0 {
+		return Pitch{}
+	}
+	silence := loudest * math.Pow(10, pitchSilence/20)
+
+	var trajectory []PitchPoint
+	diff := make([]float64, maxLag+1)
+	for start := 0; start+window+maxLag <= len(mono); start += hop {
+		if rms(mono[start:start+window]) < silence {
+			continue
+		}
+		lag, clarity := yin(mono[start:start+window+maxLag], window, minLag, maxLag, diff)
+		if lag == 0 {
+			continue
+		}
+		trajectory = append(trajectory, PitchPoint{
+			Time:      (float64(start) + float64(window+maxLag)/2) / float64(rate),
+			Frequency: float64(rate) / lag,
+			Clarity:   clarity,
+		})
+	}
+	if len(trajectory) == 0 {
+		return Pitch{}
+	}
+
+	// The tail is the last quarter of the pitched part of the signal
+	first, last := trajectory[0].Time, trajectory[len(trajectory)-1].Time
+	tailStart := last - (last-first)/4
+	var tail []float64
+	for _, p := range trajectory {
+		if p.Time >= tailStart {
+			tail = append(tail, p.Frequency)
+		}
+	}
+	sort.Float64s(tail)
+	freq := tail[len(tail)/2]
+	note, cents := FrequencyToNote(freq, 440)
+	return Pitch{
+		Frequency:  freq,
+		Note:       note,
+		Cents:      cents,
+		Trajectory: trajectory,
+	}
+}
+
+// DetectPitchInts detects the pitch of samples of the given bit depth, as returned by GenerateKickInMemory,
+// where the samples of the channels are interleaved. Like there, 8-bit samples are signed.
+func DetectPitchInts(samples []int, sampleRate, bitDepth, numChannels int) Pitch {
+	return DetectPitch(sampleRate, deinterleave(toFloats(samples, bitDepth), numChannels)...)
+}
+
+// DetectPitchWAV decodes a WAV file and detects its pitch
+func DetectPitchWAV(r io.ReadSeeker) (Pitch, error) {
+	channels, sampleRate, _, err := ReadWAV(r)
+	if err != nil {
+		return Pitch{}, err
+	}
+	return DetectPitch(sampleRate, channels...), nil
+}
+
+// yin returns the period of the given frame in samples, with sub-sample precision, and how periodic the frame is.
+// A period of 0 is returned if no period was found. diff is used as scratch space, and must hold maxLag+1 values.
+func yin(frame []float64, window, minLag, maxLag int, diff []float64) (float64, float64) {
+	// The difference function, normalized by its cumulative mean
+	diff[0] = 1
+	var sum float64
+	for lag := 1; lag <= maxLag; lag++ {
+		var d float64
+		for j := 0; j < window; j++ {
+			delta := frame[j] - frame[j+lag]
+			d += delta * delta
+		}
+		sum += d
+		if sum == 0 {
+			diff[lag] = 1
+		} else {
+			diff[lag] = d * float64(lag) / sum
+		}
+	}
+
+	// Take the first dip below the threshold, or the deepest dip if there is none
+	best := 0
+	for lag := minLag; lag <= maxLag; lag++ {
+		if diff[lag] < pitchThreshold {
+			for lag < maxLag && diff[lag+1] < diff[lag] {
+				lag++
+			}
+			best = lag
+			break
+		}
+		if best == 0 || diff[lag] < diff[best] {
+			best = lag
+		}
+	}
+	if diff[best] > 0.5 || best == maxLag {
+		return 0, 0
+	}
+
+	// Parabolic interpolation between the neighbouring lags
+	lag := float64(best)
+	if best > 1 && best < maxLag {
+		a, b, c := diff[best-1], diff[best], diff[best+1]
+		if denominator := a - 2*b + c; denominator != 0 {
+			lag += (a - c) / (2 * denominator)
+		}
+	}
+	return lag, 1 - diff[best]
+}
+
+// rms returns the root mean square of the given samples
+func rms(samples []float64) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += s * s
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
@@ -0,0 +1,30 @@
+package kick
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDetectPitchSine(t *testing.T) {
+	const sampleRate = 48000
+	for _, freq := range []float64{30, 43.65, 55, 110, 440} {
+		samples := make([]float64, sampleRate)
+		for i := range samples {
+			samples[i] = 0.5 * math.Sin(2*math.Pi*freq*float64(i)/sampleRate)
+		}
+		pitch := DetectPitch(sampleRate, samples)
+		if cents := 1200 * math.Log2(pitch.Frequency/freq); math.Abs(cents) > 5 {
+			t.Errorf("detected %.3f Hz for a %.2f Hz sine, %+.1f cents off", pitch.Frequency, freq, cents)
+		}
+		if note, _ := FrequencyToNote(freq, 440); pitch.Note != note {
+			t.Errorf("detected note %d for a %.2f Hz sine, want %d", pitch.Note, freq, note)
+		}
+		if len(pitch.Trajectory) == 0 {
+			t.Errorf("no trajectory for a %.2f Hz sine", freq)
+		}
+	}
+
+	if pitch := DetectPitch(sampleRate, make([]float64, sampleRate)); pitch.Frequency != 0 {
+		t.Errorf("detected %.2f Hz in silence", pitch.Frequency)
+	}
+}
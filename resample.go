@@ -0,0 +1,40 @@
+package kick
+
+import "math"
+
+// resampleTaps is the number of zero crossings of the sinc filter on each side, used when resampling
+const resampleTaps = 16
+
+// resample converts samples from one sample rate to another, with a Hann windowed sinc interpolator.
+// When the sample rate is lowered, the cutoff of the filter is lowered along with it, to avoid aliasing.
+func resample(samples []float64, fromRate, toRate int) []float64 {
+	if fromRate == toRate || len(samples) == 0 {
+		return append([]float64(nil), samples...)
+	}
+	ratio := float64(toRate) / float64(fromRate)
+	cutoff := math.Min(1, ratio)
+	numSamples := int(math.Ceil(float64(len(samples)) * ratio))
+	out := make([]float64, numSamples)
+
+	halfWidth := float64(resampleTaps) / cutoff
+	for i := range out {
+		center := float64(i) / ratio
+		first := int(math.Ceil(center - halfWidth))
+		last := int(math.Floor(center + halfWidth))
+		var sum float64
+		for j := first; j <= last; j++ {
+			if j < 0 || j >= len(samples) {
+				continue
+			}
+			x := (float64(j) - center) * cutoff
+			window := 0.5 + 0.5*math.Cos(math.Pi*x/resampleTaps)
+			sinc := 1.0
+			if x != 0 {
+				sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+			}
+			sum += samples[j] * sinc * window * cutoff
+		}
+		out[i] = sum
+	}
+	return out
+}
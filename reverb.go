@@ -0,0 +1,136 @@
+package kick
+
+import "math"
+
+// The comb and all-pass delay lengths of Freeverb, in samples at 44.1 kHz
+var (
+	freeverbCombTunings    = []int{1116, 1188, 1277, 1356, 1422, 1491, 1557, 1617}
+	freeverbAllPassTunings = []int{556, 441, 341, 225}
+)
+
+// freeverbStereoSpread is added to the delay lengths of the right channel, in samples at 44.1 kHz
+const freeverbStereoSpread = 23
+
+// combFilter is a feedback comb filter with a low-pass filter in the feedback path
+type combFilter struct {
+	buffer      []float64
+	index       int
+	feedback    float64
+	damping     float64
+	filterStore float64
+}
+
+func (c *combFilter) process(x float64) float64 {
+	y := c.buffer[c.index]
+	c.filterStore = y*(1-c.damping) + c.filterStore*c.damping
+	c.buffer[c.index] = x + c.filterStore*c.feedback
+	c.index = (c.index + 1) % len(c.buffer)
+	return y
+}
+
+// allPassFilter is a Schroeder all-pass filter, that diffuses the echoes from the comb filters
+type allPassFilter struct {
+	buffer []float64
+	index  int
+}
+
+func (a *allPassFilter) process(x float64) float64 {
+	delayed := a.buffer[a.index]
+	a.buffer[a.index] = x + delayed*0.5
+	a.index = (a.index + 1) % len(a.buffer)
+	return delayed - x
+}
+
+// freeverb is one channel of a Freeverb-style reverb: parallel comb filters followed by all-pass filters in series
+type freeverb struct {
+	combs     []*combFilter
+	allPasses []*allPassFilter
+}
+
+func newFreeverb(size, damping float64, spread, sampleRate int) *freeverb {
+	scale := float64(sampleRate) / 44100
+	r := &freeverb{}
+	for _, tuning := range freeverbCombTunings {
+		r.combs = append(r.combs, &combFilter{
+			buffer:   make([]float64, int(float64(tuning+spread)*scale)+1),
+			feedback: reverbFeedback(size),
+			damping:  0.4 * damping,
+		})
+	}
+	for _, tuning := range freeverbAllPassTunings {
+		r.allPasses = append(r.allPasses, &allPassFilter{
+			buffer: make([]float64, int(float64(tuning+spread)*scale)+1),
+		})
+	}
+	return r
+}
+
+func (r *freeverb) process(x float64) float64 {
+	var y float64
+	for _, c := range r.combs {
+		y += c.process(x)
+	}
+	for _, a := range r.allPasses {
+		y = a.process(y)
+	}
+	return y
+}
+
+// reverbFeedback maps a room size from 0 to 1 to the feedback of the comb filters
+func reverbFeedback(size float64) float64 {
+	return 0.7 + 0.28*math.Max(0, math.Min(1, size))
+}
+
+// reverbTail returns how long the reverb rings out after the kick, which is either ReverbTail,
+// or the time the longest comb filter takes to decay by 60 dB, plus the pre-delay
+func (cfg *Settings) reverbTail() float64 {
+	if cfg.ReverbTail > 0 {
+		return cfg.ReverbTail
+	}
+	longest := float64(freeverbCombTunings[len(freeverbCombTunings)-1]+freeverbStereoSpread) / 44100
+	return -3*longest/math.Log10(reverbFeedback(cfg.ReverbSize)) + cfg.ReverbPreDelay
+}
+
+// applyReverb mixes a Freeverb-style reverb into the given channels, if ReverbMix is above 0. The channels are
+// extended with the reverb tail, so the returned channels can be longer than the given ones. The wet signal is
+// high-pass filtered at ReverbLowCut, so that the sub-bass stays dry.
+func (cfg *Settings) applyReverb(channels [][]float64) [][]float64 {
+	if cfg.ReverbMix <= 0 || len(channels) == 0 {
+		return channels
+	}
+	mix := math.Min(1, cfg.ReverbMix)
+
+	tail := int(cfg.reverbTail() * float64(cfg.SampleRate))
+	preDelay := int(cfg.ReverbPreDelay * float64(cfg.SampleRate))
+
+	extended := make([][]float64, len(channels))
+	for c, dry := range channels {
+		out := make([]float64, len(dry)+tail)
+		copy(out, dry)
+
+		reverb := newFreeverb(cfg.ReverbSize, cfg.ReverbDamping, c*freeverbStereoSpread, cfg.SampleRate)
+		var lowCut *crossover
+		if cfg.ReverbLowCut > 0 {
+			lowCut = newCrossover(biquadHighPass, cfg.ReverbLowCut, cfg.SampleRate)
+		}
+
+		for i := range out {
+			var in float64
+			if j := i - preDelay; j >= 0 && j < len(dry) {
+				in = dry[j]
+			}
+			// The input gain and the wet scaling are the ones used by Freeverb
+			wet := reverb.process(in*0.015) * 3
+			if lowCut != nil {
+				wet = lowCut.process(wet)
+			}
+			var d float64
+			if i < len(dry) {
+				d = dry[i]
+			}
+			out[i] = d*(1-mix) + wet*mix
+		}
+		extended[c] = out
+	}
+	return extended
+}
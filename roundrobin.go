@@ -0,0 +1,54 @@
+package kick
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"strings"
+)
+
+// Variation holds the largest deviations from the settings for round-robin variants.
+// Each variant gets a random deviation within plus or minus each of these.
+type Variation struct {
+	Pitch float64 // in cents, applied to both StartFreq and EndFreq
+	Decay float64 // relative change of Decay, like 0.05 for 5%
+	Click float64 // relative change of ClickLevel
+	Noise float64 // relative change of NoiseAmount
+	Phase float64 // in degrees, set as PhaseOffset
+}
+
+// DefaultVariation gives variants that sound like the same kick being hit again
+var DefaultVariation = Variation{Pitch: 5, Decay: 0.05, Click: 0.1, Noise: 0.1, Phase: 10}
+
+// Variants returns n copies of the settings, each with small random deviations within the given variation.
+// Each variant also gets its own NoiseSeed. The same seed always gives the same variants.
+func (cfg *Settings) Variants(n int, variation Variation, seed int64) []*Settings {
+	r := rand.New(rand.NewSource(seed))
+	deviation := func(limit float64) float64 {
+		return (r.Float64()*2 - 1) * limit
+	}
+	variants := make([]*Settings, n)
+	for i := range variants {
+		v := CopySettings(cfg)
+		pitch := math.Pow(2, deviation(variation.Pitch)/1200)
+		v.StartFreq *= pitch
+		v.EndFreq *= pitch
+		v.Decay = math.Max(0.001, v.Decay*(1+deviation(variation.Decay)))
+		v.ClickLevel = math.Max(0, v.ClickLevel*(1+deviation(variation.Click)))
+		v.NoiseAmount = math.Max(0, math.Min(1, v.NoiseAmount*(1+deviation(variation.Noise))))
+		// The phase deviation is kept apart from StartPhase, so that it still applies with AutoPhase,
+		// and so that the fade-in and the attack are kept for kicks that start at 0
+		v.PhaseOffset = deviation(variation.Phase)
+		v.NoiseSeed = r.Int63()
+		variants[i] = v
+	}
+	return variants
+}
+
+// RoundRobinPath returns the path for the given round-robin variant, counting from 1,
+// like kick_rr01.wav for kick.wav
+func RoundRobinPath(path string, i int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_rr%02d%s", strings.TrimSuffix(path, ext), i, ext)
+}
@@ -0,0 +1,256 @@
+package kick
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Scale is a musical scale from a Scala .scl file
+type Scale struct {
+	Description string
+	Pitches     []float64 // the degrees of the scale in cents above the root, where the last one is the period, usually 1200
+}
+
+// KeyboardMapping maps MIDI note numbers to scale degrees, as in a Scala .kbm file
+type KeyboardMapping struct {
+	FirstNote          int     // lowest MIDI note number to retune
+	LastNote           int     // highest MIDI note number to retune
+	MiddleNote         int     // MIDI note number where the first degree of the scale is mapped to
+	ReferenceNote      int     // MIDI note number that the reference frequency is given for
+	ReferenceFrequency float64 // frequency of the reference note, in Hz
+	OctaveDegree       int     // scale degree that the mapping repeats at, 0 for the period of the scale
+	Mapping            []int   // scale degree for each key in the mapping, -1 for unmapped keys. Empty for a linear mapping.
+}
+
+// Tuning combines a scale with a keyboard mapping, to resolve MIDI note numbers to frequencies
+type Tuning struct {
+	Scale   *Scale
+	Mapping *KeyboardMapping // nil for the default mapping, with the first degree at note 60 and A4 at note 69
+}
+
+// scalaLines returns the lines of a Scala file that are not comments
+func scalaLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// firstField returns the first whitespace separated field of a line, since anything after it is ignored in Scala files
+func firstField(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// parsePitch parses a pitch from a .scl file. Pitches with a period are in cents, all others are ratios like 3/2 or 2.
+func parsePitch(s string) (float64, error) {
+	if strings.Contains(s, ".") {
+		return strconv.ParseFloat(s, 64)
+	}
+	numerator, denominator := s, "1"
+	if i := strings.Index(s, "/"); i >= 0 {
+		numerator, denominator = s[:i], s[i+1:]
+	}
+	n, err := strconv.ParseFloat(numerator, 64)
+	if err != nil {
+		return 0, err
+	}
+	d, err := strconv.ParseFloat(denominator, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 || d <= 0 {
+		return 0, fmt.Errorf("invalid ratio %q", s)
+	}
+	return 1200 * math.Log2(n/d), nil
+}
+
+// ParseScala parses a Scala .scl scale file
+func ParseScala(r io.Reader) (*Scale, error) {
+	lines, err := scalaLines(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) < 2 {
+		return nil, errors.New("the scale file is missing a description or the number of notes")
+	}
+	count, err := strconv.Atoi(firstField(lines[1]))
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid number of notes: %q", lines[1])
+	}
+
+	scale := &Scale{Description: strings.TrimSpace(lines[0])}
+	for _, line := range lines[2:] {
+		field := firstField(line)
+		if field == "" {
+			continue
+		}
+		if len(scale.Pitches) == count {
+			break
+		}
+		pitch, err := parsePitch(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pitch %q: %v", field, err)
+		}
+		scale.Pitches = append(scale.Pitches, pitch)
+	}
+	if len(scale.Pitches) != count || count == 0 {
+		return nil, fmt.Errorf("expected %d pitches, found %d", count, len(scale.Pitches))
+	}
+	return scale, nil
+}
+
+// ParseKeyboardMapping parses a Scala .kbm keyboard mapping file
+func ParseKeyboardMapping(r io.Reader) (*KeyboardMapping, error) {
+	lines, err := scalaLines(r)
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	for _, line := range lines {
+		if field := firstField(line); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) < 7 {
+		return nil, errors.New("the keyboard mapping file is missing header values")
+	}
+
+	header := make([]int, 7)
+	for i := range header {
+		if i == 5 {
+			continue
+		}
+		if header[i], err = strconv.Atoi(fields[i]); err != nil {
+			return nil, fmt.Errorf("invalid value %q in the keyboard mapping header", fields[i])
+		}
+	}
+	referenceFrequency, err := strconv.ParseFloat(fields[5], 64)
+	if err != nil || referenceFrequency <= 0 {
+		return nil, fmt.Errorf("invalid reference frequency %q", fields[5])
+	}
+
+	mapping := &KeyboardMapping{
+		FirstNote:          header[1],
+		LastNote:           header[2],
+		MiddleNote:         header[3],
+		ReferenceNote:      header[4],
+		ReferenceFrequency: referenceFrequency,
+		OctaveDegree:       header[6],
+	}
+	size := header[0]
+	for _, field := range fields[7:] {
+		if len(mapping.Mapping) == size {
+			break
+		}
+		if strings.EqualFold(field, "x") {
+			mapping.Mapping = append(mapping.Mapping, -1)
+			continue
+		}
+		degree, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scale degree %q in the keyboard mapping", field)
+		}
+		mapping.Mapping = append(mapping.Mapping, degree)
+	}
+	// Keys that are left out at the end of the mapping are unmapped
+	for len(mapping.Mapping) < size {
+		mapping.Mapping = append(mapping.Mapping, -1)
+	}
+	return mapping, nil
+}
+
+// LoadScala loads a Scala .scl scale file
+func LoadScala(path string) (*Scale, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseScala(file)
+}
+
+// LoadKeyboardMapping loads a Scala .kbm keyboard mapping file
+func LoadKeyboardMapping(path string) (*KeyboardMapping, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseKeyboardMapping(file)
+}
+
+// degreeCents returns the pitch of the given scale degree in cents above the root, where degrees
+// beyond the size of the scale continue in the next periods
+func (s *Scale) degreeCents(degree int) float64 {
+	n := len(s.Pitches)
+	period := s.Pitches[n-1]
+	octaves := int(math.Floor(float64(degree) / float64(n)))
+	degree -= octaves * n
+	cents := float64(octaves) * period
+	if degree > 0 {
+		cents += s.Pitches[degree-1]
+	}
+	return cents
+}
+
+// noteCents returns the pitch of the given MIDI note number in cents above the middle note,
+// or false if the note is not mapped
+func (t *Tuning) noteCents(note int) (float64, bool) {
+	m := t.Mapping
+	if m == nil || len(m.Mapping) == 0 {
+		middle := 60
+		if m != nil {
+			middle = m.MiddleNote
+		}
+		return t.Scale.degreeCents(note - middle), true
+	}
+	size := len(m.Mapping)
+	offset := note - m.MiddleNote
+	repeats := int(math.Floor(float64(offset) / float64(size)))
+	degree := m.Mapping[offset-repeats*size]
+	if degree < 0 {
+		return 0, false
+	}
+	octaveDegree := m.OctaveDegree
+	if octaveDegree <= 0 {
+		octaveDegree = len(t.Scale.Pitches)
+	}
+	return float64(repeats)*t.Scale.degreeCents(octaveDegree) + t.Scale.degreeCents(degree), true
+}
+
+// Frequency returns the frequency of the given MIDI note number in the tuning. The given frequency of A4
+// is only used when there is no keyboard mapping, with note 69 as the reference note.
+func (t *Tuning) Frequency(note int, a4 float64) (float64, error) {
+	referenceNote, referenceFrequency := 69, a4
+	if t.Mapping != nil {
+		if note < t.Mapping.FirstNote || note > t.Mapping.LastNote {
+			return 0, fmt.Errorf("note %d is outside of the keyboard mapping", note)
+		}
+		referenceNote, referenceFrequency = t.Mapping.ReferenceNote, t.Mapping.ReferenceFrequency
+	}
+	cents, ok := t.noteCents(note)
+	if !ok {
+		return 0, fmt.Errorf("note %d is not mapped to a scale degree", note)
+	}
+	referenceCents, ok := t.noteCents(referenceNote)
+	if !ok {
+		return 0, fmt.Errorf("the reference note %d is not mapped to a scale degree", referenceNote)
+	}
+	return referenceFrequency * math.Pow(2, (cents-referenceCents)/1200), nil
+}
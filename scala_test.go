@@ -0,0 +1,117 @@
+package kick
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const equalTemperament = `! 12tet.scl
+!
+12-tone equal temperament
+ 12
+!
+ 100.0
+ 200.
+ 300.0
+ 400.0
+ 500.0
+ 600.0
+ 700.0
+ 800.0
+ 900.0
+ 1000.0
+ 1100.0
+ 2/1
+`
+
+// A keyboard mapping with unmapped keys, and D4 at 300 Hz as the reference
+const sparseMapping = `! sparse.kbm
+12
+0
+100
+60
+62
+300.0
+12
+! The mapping, where E flat and the last key are unmapped
+0
+1
+2
+x
+4
+5
+6
+7
+8
+9
+10
+`
+
+func TestScalaEqualTemperament(t *testing.T) {
+	scale, err := ParseScala(strings.NewReader(equalTemperament))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scale.Description != "12-tone equal temperament" || len(scale.Pitches) != 12 || scale.Pitches[11] != 1200 {
+		t.Fatalf("got %q with pitches %v", scale.Description, scale.Pitches)
+	}
+	tuning := &Tuning{Scale: scale}
+	for _, note := range []int{21, 29, 60, 69, 81} {
+		got, err := tuning.Frequency(note, 440)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := NoteFrequency(note, 440, 0); math.Abs(got-want) > 1e-9 {
+			t.Errorf("note %d: got %.4f Hz, want %.4f Hz", note, got, want)
+		}
+	}
+
+	for _, input := range []string{"", "missing count\n", "too few\n 2\n 100.0\n", "bad pitch\n 1\n abc\n"} {
+		if _, err := ParseScala(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseScala(%q) did not return an error", input)
+		}
+	}
+}
+
+func TestScalaKeyboardMapping(t *testing.T) {
+	scale, err := ParseScala(strings.NewReader(equalTemperament))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := ParseKeyboardMapping(strings.NewReader(sparseMapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mapping.Mapping) != 12 || mapping.Mapping[3] != -1 || mapping.Mapping[11] != -1 {
+		t.Fatalf("got the mapping %v", mapping.Mapping)
+	}
+	tuning := &Tuning{Scale: scale, Mapping: mapping}
+
+	tests := []struct {
+		note int
+		freq float64
+	}{
+		{62, 300},
+		{60, 300 * math.Pow(2, -200.0/1200)},
+		{64, 300 * math.Pow(2, 200.0/1200)},
+		{74, 600},
+		{50, 150},
+	}
+	for _, test := range tests {
+		got, err := tuning.Frequency(test.note, 440)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-test.freq) > 1e-9 {
+			t.Errorf("note %d: got %.4f Hz, want %.4f Hz", test.note, got, test.freq)
+		}
+	}
+
+	// Unmapped keys, in any octave, and notes outside of the mapping can not be tuned
+	for _, note := range []int{63, 75, 71, 101} {
+		if _, err := tuning.Frequency(note, 440); err == nil {
+			t.Errorf("note %d did not return an error", note)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package kick
+
+// RenderStats holds measurements from the last time a kick was rendered
+type RenderStats struct {
+	StartPhase           float64           // start phase of the oscillators, in degrees, as set or picked by AutoPhase
+	MaxGainReduction     float64           // largest gain reduction by the compressor, in dB
+	AverageGainReduction float64           // average gain reduction by the compressor, in dB
+	DCOffsetBefore       float64           // DC offset before the DC blocker and the sub-sonic filter, from -1 to 1
+	DCOffsetAfter        float64           // DC offset after the DC blocker and the sub-sonic filter, from -1 to 1
+	InputPeak            float64           // sample peak before the output stage, in dBFS
+	ClippedSamples       int               // number of samples above full scale before the output stage
+	OutputGain           float64           // gain applied by the normalization in the output stage, in dB
+	LimiterReduction     float64           // largest gain reduction by the true-peak limiter, in dB
+	TruePeak             float64           // true peak of the output, in dBTP
+	ClippedOutput        int               // number of samples that had to be clipped when writing the output
+	TrimmedEnd           int               // number of samples trimmed from the end
+	Length               float64           // length of the output, in seconds
+	Correlation          StereoCorrelation // mono compatibility of stereo output
+}
@@ -0,0 +1,130 @@
+package kick
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StemPath returns the path for the stem with the given name, like kick_body.wav for kick.wav
+func StemPath(path, name string) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_%s%s", strings.TrimSuffix(path, ext), name, ext)
+}
+
+// stemSettings returns copies of the settings with only one component left in each, by stem name.
+// The body holds all the oscillators, and when there are several, each one also gets a stem of its own.
+// The start phase that was used for the last render of cfg is pinned in all of them, since AutoPhase
+// would otherwise pick a different phase for each oscillator on its own.
+func (cfg *Settings) stemSettings() ([]string, []*Settings) {
+	names := []string{"body"}
+	hasStartPhase := cfg.hasStartPhase()
+	body := CopySettings(cfg)
+	body.StartPhase = cfg.Stats.StartPhase
+	body.AutoPhase = AutoPhaseNone
+	body.PhaseOffset = 0
+	body.startPhaseSet = &hasStartPhase
+	body.NoiseAmount = 0
+	body.ClickLevel = 0
+	stems := []*Settings{body}
+
+	if cfg.NumOscillators > 1 {
+		for osc := 0; osc < cfg.NumOscillators; osc++ {
+			stem := CopySettings(body)
+			for i := range stem.OscillatorLevels {
+				if i != osc {
+					stem.OscillatorLevels[i] = 0
+				}
+			}
+			names = append(names, fmt.Sprintf("osc%d", osc+1))
+			stems = append(stems, stem)
+		}
+	}
+
+	silent := CopySettings(body)
+	silent.NoiseAmount = cfg.NoiseAmount
+	silent.ClickLevel = cfg.ClickLevel
+	for i := range silent.OscillatorLevels {
+		silent.OscillatorLevels[i] = 0
+	}
+	if cfg.NoiseType != NoiseNone && cfg.NoiseAmount > 0 {
+		noise := CopySettings(silent)
+		noise.ClickLevel = 0
+		names = append(names, "noise")
+		stems = append(stems, noise)
+	}
+	if cfg.ClickLevel > 0 {
+		click := CopySettings(silent)
+		click.NoiseAmount = 0
+		names = append(names, "click")
+		stems = append(stems, click)
+	}
+	return names, stems
+}
+
+// writeStem applies the output gain and the trimming of the last render of cfg to the given channels,
+// so that the stem lines up with the mix, and writes it as a WAV file
+func (cfg *Settings) writeStem(path string, channels [][]float64) error {
+	applyGain(cfg.Stats.OutputGain+toDB(cfg.velocityGain()), channels...)
+	for c, samples := range channels {
+		channels[c] = samples[:max(1, len(samples)-cfg.Stats.TrimmedEnd)]
+	}
+	if cfg.TrimThreshold < 0 || cfg.TrimZeroCrossings {
+		fadeOut(cfg.SampleRate, cfg.AntiClickFade, channels...)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return WriteWAV(file, channels, cfg.SampleRate, cfg.BitDepth)
+}
+
+// WriteStems renders the oscillators, the noise and the click of the kick on their own, through the
+// same effects chain, and writes them next to the given path, like kick_body.wav, kick_noise.wav and
+// kick_click.wav for kick.wav. It should be called after GenerateKick, so that the stems get the same
+// output gain and trimming as the mix and stay sample-aligned with it. The noise is seeded by NoiseSeed,
+// so the noise stem is the same noise as in the mix. The stems only add up to the mix when the chain is
+// linear, since nonlinear stages like the saturator and the compressor act on each stem on its own.
+// The stems get the output gain of the mix, but not its limiter, so with Limiter set they can peak higher.
+// The written paths are returned.
+func (cfg *Settings) WriteStems(path string) ([]string, error) {
+	names, stems := cfg.stemSettings()
+	var paths []string
+	for i, stem := range stems {
+		channels, err := stem.renderChannels()
+		if err != nil {
+			return paths, err
+		}
+		stemPath := StemPath(path, names[i])
+		if err := cfg.writeStem(stemPath, channels); err != nil {
+			return paths, err
+		}
+		paths = append(paths, stemPath)
+	}
+	return paths, nil
+}
+
+// WriteStems writes each active layer, as it was placed in the last mix, next to the given path,
+// like kick_layer1.wav for kick.wav. It should be called after GenerateKick, so that the stems get
+// the same output gain and trimming as the mix. The written paths are returned.
+func (l *Layered) WriteStems(path string) ([]string, error) {
+	var paths []string
+	for i, layer := range l.activeLayers() {
+		if layer.placed == nil {
+			return paths, fmt.Errorf("layer %d has not been mixed yet", i+1)
+		}
+		channels := make([][]float64, len(layer.placed))
+		for c, samples := range layer.placed {
+			channels[c] = append([]float64(nil), samples...)
+		}
+		stemPath := StemPath(path, fmt.Sprintf("layer%d", i+1))
+		if err := l.Master.writeStem(stemPath, channels); err != nil {
+			return paths, err
+		}
+		paths = append(paths, stemPath)
+	}
+	return paths, nil
+}
@@ -0,0 +1,40 @@
+package kick
+
+import (
+	"math"
+	"testing"
+)
+
+// The oscillator stems should start at the phase that AutoPhase picked for the mix, and add up to the body
+func TestStemSettingsPhase(t *testing.T) {
+	cfg, err := New909(48000, 0.3, 16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.NumOscillators = 2
+	cfg.OscillatorLevels = []float64{1, 1}
+	cfg.OscillatorPhases = []float64{0, 90}
+	cfg.AutoPhase = AutoPhasePunch
+	if _, err := cfg.Render(); err != nil {
+		t.Fatal(err)
+	}
+
+	names, stems := cfg.stemSettings()
+	for i, stem := range stems {
+		if phase := stem.startPhase(); phase != cfg.Stats.StartPhase {
+			t.Errorf("the %s stem starts at %.0f degrees, want %.0f", names[i], phase, cfg.Stats.StartPhase)
+		}
+		if stem.hasStartPhase() != cfg.hasStartPhase() {
+			t.Errorf("the %s stem has a different start phase handling than the mix", names[i])
+		}
+	}
+
+	body := stems[0].generateMultiOscillatorSamples(0, 0)
+	osc1 := stems[1].generateMultiOscillatorSamples(0, 0)
+	osc2 := stems[2].generateMultiOscillatorSamples(0, 0)
+	for i := range body {
+		if math.Abs(osc1[i]+osc2[i]-body[i]) > 1e-9 {
+			t.Fatalf("the oscillator stems do not add up to the body at sample %d", i)
+		}
+	}
+}
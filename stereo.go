@@ -0,0 +1,150 @@
+package kick
+
+import "math"
+
+// generateChannels generates one channel for mono output, or two for stereo output. In stereo, the band below
+// StereoCrossover stays mono, while the band above it is spread out with per-channel detune, a micro-delay on
+// the right channel and independent noise, by an amount set by StereoWidth. After nonlinear stages that work on
+// each channel, like the saturator, the sub band is taken from the processed mid, so that it stays the same in both.
+func (cfg *Settings) generateChannels() [][]float64 {
+	mid := cfg.generateMultiOscillatorSamples(0, 0)
+	if cfg.Channels != 2 {
+		return cfg.applyModPan([][]float64{mid})
+	}
+
+	left := cfg.generateMultiOscillatorSamples(-cfg.StereoDetune/2, 1)
+	right := cfg.generateMultiOscillatorSamples(cfg.StereoDetune/2, 2)
+
+	width := math.Max(0, math.Min(1, cfg.StereoWidth))
+	delay := int(cfg.StereoDelay * float64(cfg.SampleRate))
+
+	lowPass := newCrossover(biquadLowPass, cfg.StereoCrossover, cfg.SampleRate)
+	leftHighPass := newCrossover(biquadHighPass, cfg.StereoCrossover, cfg.SampleRate)
+	rightHighPass := newCrossover(biquadHighPass, cfg.StereoCrossover, cfg.SampleRate)
+
+	low := make([]float64, len(mid))
+	for i := range mid {
+		low[i] = lowPass.process(mid[i])
+		left[i] = low[i] + leftHighPass.process((1-width)*mid[i]+width*left[i])
+		right[i] = low[i] + rightHighPass.process((1-width)*mid[i]+width*right[i])
+	}
+
+	// Delay the high band of the right channel by moving it against the shared low band
+	if delay > 0 && delay < len(right) {
+		for i := len(right) - 1; i >= 0; i-- {
+			high := 0.0
+			if i >= delay {
+				high = right[i-delay] - low[i-delay]
+			}
+			right[i] = low[i] + high
+		}
+	}
+
+	return cfg.applyModPan([][]float64{left, right})
+}
+
+// applyStereoNonlinear runs a nonlinear process, like the saturator, on each channel. For stereo output, the band
+// below StereoCrossover is then replaced by the same band of the processed mid, so that the sub stays mono.
+// Mono kicks that were made stereo by a pan route are left as they are, so that the pan still moves the sub.
+func (cfg *Settings) applyStereoNonlinear(channels [][]float64, process func(samples []float64)) {
+	if len(channels) != 2 || cfg.Channels != 2 {
+		for _, samples := range channels {
+			process(samples)
+		}
+		return
+	}
+	left, right := channels[0], channels[1]
+	mid := make([]float64, len(left))
+	for i := range left {
+		mid[i] = (left[i] + right[i]) / 2
+	}
+	process(left)
+	process(right)
+	process(mid)
+	lowPass := newCrossover(biquadLowPass, cfg.StereoCrossover, cfg.SampleRate)
+	leftHighPass := newCrossover(biquadHighPass, cfg.StereoCrossover, cfg.SampleRate)
+	rightHighPass := newCrossover(biquadHighPass, cfg.StereoCrossover, cfg.SampleRate)
+	for i := range left {
+		low := lowPass.process(mid[i])
+		left[i] = low + leftHighPass.process(left[i])
+		right[i] = low + rightHighPass.process(right[i])
+	}
+}
+
+// crossover is a 4th order Linkwitz-Riley filter, made from two Butterworth sections
+type crossover struct {
+	first, second *biquad
+}
+
+func newCrossover(kind int, freq float64, sampleRate int) *crossover {
+	return &crossover{
+		first:  newBiquad(kind, freq, math.Sqrt2/2, sampleRate),
+		second: newBiquad(kind, freq, math.Sqrt2/2, sampleRate),
+	}
+}
+
+func (c *crossover) process(x float64) float64 {
+	return c.second.process(c.first.process(x))
+}
+
+// StereoCorrelation holds measurements of how well a stereo signal survives being summed to mono
+type StereoCorrelation struct {
+	Correlation    float64 // correlation over the whole signal, from -1 (out of phase) to 1 (mono)
+	MinCorrelation float64 // lowest correlation over 50 ms windows
+	MonoLoss       float64 // level lost by summing to mono, in dB
+}
+
+// Correlation returns the correlation between the left and the right channel, from -1 to 1
+func Correlation(left, right []float64) float64 {
+	var lr, ll, rr float64
+	for i := 0; i < len(left) && i < len(right); i++ {
+		lr += left[i] * right[i]
+		ll += left[i] * left[i]
+		rr += right[i] * right[i]
+	}
+	if ll == 0 || rr == 0 {
+		return 1
+	}
+	return lr / math.Sqrt(ll*rr)
+}
+
+// MeasureCorrelation measures the mono compatibility of a stereo signal
+func MeasureCorrelation(sampleRate int, left, right []float64) StereoCorrelation {
+	result := StereoCorrelation{
+		Correlation:    Correlation(left, right),
+		MinCorrelation: 1,
+	}
+
+	numSamples := len(left)
+	if len(right) < numSamples {
+		numSamples = len(right)
+	}
+
+	// Windows that are nearly silent say little about the mono compatibility, so they are skipped
+	window := sampleRate / 20
+	if window < 1 {
+		window = 1
+	}
+	for start := 0; start+window <= numSamples; start += window {
+		var energy float64
+		for i := start; i < start+window; i++ {
+			energy += left[i]*left[i] + right[i]*right[i]
+		}
+		if energy/float64(2*window) < 1e-6 {
+			continue
+		}
+		result.MinCorrelation = math.Min(result.MinCorrelation, Correlation(left[start:start+window], right[start:start+window]))
+	}
+
+	var stereoPower, monoPower float64
+	for i := 0; i < numSamples; i++ {
+		stereoPower += (left[i]*left[i] + right[i]*right[i]) / 2
+		mono := (left[i] + right[i]) / 2
+		monoPower += mono * mono
+	}
+	if stereoPower > 0 {
+		result.MonoLoss = -toDB(math.Sqrt(monoPower / stereoPower))
+	}
+
+	return result
+}
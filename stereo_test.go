@@ -0,0 +1,50 @@
+package kick
+
+import "testing"
+
+// The band below the crossover should stay mono through the effects chain
+func TestStereoMonoSub(t *testing.T) {
+	cfg, err := New909(48000, 0.5, 16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Channels = 2
+	cfg.StereoWidth = 1
+	cfg.SaturatorAmount = 2
+	buffer, err := cfg.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, right := buffer.Channels[0], buffer.Channels[1]
+	lowPass := newCrossover(biquadLowPass, cfg.StereoCrossover/4, cfg.SampleRate)
+	lowSide := make([]float64, len(left))
+	for i := range left {
+		lowSide[i] = lowPass.process(left[i] - right[i])
+	}
+	level := toDB(rms(lowSide) / rms(left))
+	if level > -80 {
+		t.Errorf("the difference between the channels below the crossover is at %.1f dB, want below -80 dB", level)
+	}
+}
+
+// A pan route on a mono kick should still pan the sub after the saturator
+func TestStereoModPanSub(t *testing.T) {
+	cfg, err := New909(48000, 0.5, 16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.SaturatorAmount = 2
+	cfg.ModSources = []ModSource{{Type: ModSourceEnvelope, Decay: 10, Sustain: 1}}
+	cfg.ModRoutes = []ModRoute{{Source: 0, Destination: ModPan, Depth: 1}}
+	buffer, err := cfg.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buffer.Channels) != 2 {
+		t.Fatalf("got %d channels, want 2", len(buffer.Channels))
+	}
+	left, right := buffer.Channels[0], buffer.Channels[1]
+	if level := toDB(rms(left) / rms(right)); level > -20 {
+		t.Errorf("the left channel of a kick panned to the right is at %.1f dB, want below -20 dB", level)
+	}
+}
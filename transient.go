@@ -0,0 +1,65 @@
+package kick
+
+import "math"
+
+// envelopeFollower tracks the level of a signal, with separate attack and release times
+type envelopeFollower struct {
+	attack, release float64
+	level           float64
+}
+
+func newEnvelopeFollower(attack, release float64, sampleRate int) *envelopeFollower {
+	return &envelopeFollower{
+		attack:  timeConstant(attack, sampleRate),
+		release: timeConstant(release, sampleRate),
+	}
+}
+
+func (e *envelopeFollower) process(x float64) float64 {
+	if x > e.level {
+		e.level = e.attack*e.level + (1-e.attack)*x
+	} else {
+		e.level = e.release*e.level + (1-e.release)*x
+	}
+	return e.level
+}
+
+// ApplyTransientShaper boosts or softens the attack and the sustain of the given channels, by the given gains in dB.
+// The attack is detected where a fast envelope follower is ahead of a slow one, and the sustain where a slowly
+// releasing envelope follower stays above a quickly releasing one. The gain is computed for every sample, from the
+// loudest channel, and applied to all channels.
+func ApplyTransientShaper(sampleRate int, attackGain, sustainGain float64, channels ...[]float64) {
+	if len(channels) == 0 || (attackGain == 0 && sustainGain == 0) {
+		return
+	}
+
+	fastAttack := newEnvelopeFollower(0.0005, 0.05, sampleRate)
+	slowAttack := newEnvelopeFollower(0.015, 0.05, sampleRate)
+	fastRelease := newEnvelopeFollower(0.0005, 0.02, sampleRate)
+	slowRelease := newEnvelopeFollower(0.0005, 0.2, sampleRate)
+
+	for i := range channels[0] {
+		var level float64
+		for _, ch := range channels {
+			level = math.Max(level, math.Abs(ch[i]))
+		}
+
+		fa := fastAttack.process(level)
+		sa := slowAttack.process(level)
+		fr := fastRelease.process(level)
+		sr := slowRelease.process(level)
+
+		var attackAmount, sustainAmount float64
+		if fa > 1e-9 {
+			attackAmount = math.Max(0, (fa-sa)/fa)
+		}
+		if sr > 1e-9 {
+			sustainAmount = math.Max(0, (sr-fr)/sr)
+		}
+
+		gain := math.Pow(10, (attackGain*attackAmount+sustainGain*sustainAmount)/20)
+		for _, ch := range channels {
+			ch[i] *= gain
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package kick
+
+import "math"
+
+// zeroCrossingSearch is how far the end of a trimmed kick may move to find a zero crossing, in seconds
+const zeroCrossingSearch = 0.02
+
+// isZeroCrossing returns true if the signal crosses or touches zero between the given sample and the one before it
+func isZeroCrossing(samples []float64, i int) bool {
+	if samples[i] == 0 {
+		return true
+	}
+	return i > 0 && (samples[i-1] < 0) != (samples[i] < 0)
+}
+
+// zeroCrossingSample returns the sample nearest to zero of the two around the zero crossing that ends at i
+func zeroCrossingSample(samples []float64, i int) int {
+	if i > 0 && math.Abs(samples[i-1]) < math.Abs(samples[i]) {
+		return i - 1
+	}
+	return i
+}
+
+// mixDown returns the average of the given channels
+func mixDown(channels ...[]float64) []float64 {
+	mono := make([]float64, len(channels[0]))
+	for _, samples := range channels {
+		for i, s := range samples {
+			mono[i] += s / float64(len(channels))
+		}
+	}
+	return mono
+}
+
+// TrimTail trims trailing audio that stays below the threshold in dBFS (0 to keep the full length), moves the end
+// to a zero crossing if zeroCrossings is true, and fades out the last fade seconds. The start is never trimmed or
+// faded, so that any pre-delay and the timing in a sampler are kept, and a kick can start at full level for punch.
+// The trimmed channels are returned along with the number of samples that were removed from the end.
+func TrimTail(sampleRate int, threshold float64, zeroCrossings bool, fade float64, channels ...[]float64) ([][]float64, int) {
+	if len(channels) == 0 || len(channels[0]) == 0 {
+		return channels, 0
+	}
+	mono := mixDown(channels...)
+	length := len(mono)
+	end := length
+
+	if threshold < 0 {
+		level := math.Pow(10, threshold/20)
+		last := 0
+		for i := 0; i < length; i++ {
+			for _, samples := range channels {
+				if math.Abs(samples[i]) > level {
+					last = i
+				}
+			}
+		}
+		end = last + 1
+	}
+
+	if zeroCrossings {
+		// The sample nearest to zero at the crossing is the last sample that is kept
+		search := int(zeroCrossingSearch * float64(sampleRate))
+		// Move the end forward to the next zero crossing, or back to the last one if there is none
+		found := false
+		for i := end; i < min(length, end+search); i++ {
+			if isZeroCrossing(mono, i) {
+				end, found = zeroCrossingSample(mono, i)+1, true
+				break
+			}
+		}
+		for i := end - 1; !found && i > max(0, end-search); i-- {
+			if isZeroCrossing(mono, i) {
+				end, found = zeroCrossingSample(mono, i)+1, true
+			}
+		}
+	}
+	end = max(end, 1)
+
+	trimmed := make([][]float64, len(channels))
+	for c, samples := range channels {
+		trimmed[c] = samples[:end]
+	}
+
+	// Fade out the end, so that it can not click even if there was no zero crossing nearby
+	fadeOut(sampleRate, fade, trimmed...)
+
+	return trimmed, length - end
+}
+
+// fadeOut fades out the last fade seconds of the given channels linearly
+func fadeOut(sampleRate int, fade float64, channels ...[]float64) {
+	for _, samples := range channels {
+		fadeSamples := min(int(fade*float64(sampleRate)), len(samples))
+		for i := 0; i < fadeSamples; i++ {
+			samples[len(samples)-1-i] *= float64(i) / float64(fadeSamples)
+		}
+	}
+}
+
+// applyTrim trims the rendered kick according to the settings, and records how much was removed
+func (cfg *Settings) applyTrim(channels [][]float64) [][]float64 {
+	cfg.Stats.TrimmedEnd = 0
+	if cfg.TrimThreshold < 0 || cfg.TrimZeroCrossings {
+		channels, cfg.Stats.TrimmedEnd = TrimTail(cfg.SampleRate, cfg.TrimThreshold, cfg.TrimZeroCrossings, cfg.AntiClickFade, channels...)
+	}
+	cfg.Stats.Length = float64(len(channels[0])) / float64(cfg.SampleRate)
+	return channels
+}
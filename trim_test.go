@@ -0,0 +1,37 @@
+package kick
+
+import (
+	"math"
+	"testing"
+)
+
+// The start should be kept, and the last kept sample should be the one nearest to zero at the crossing
+func TestTrimTailZeroCrossings(t *testing.T) {
+	const sampleRate = 48000
+	samples := make([]float64, sampleRate/10)
+	for i := range samples {
+		samples[i] = math.Sin(2*math.Pi*110.3*float64(i)/sampleRate + 0.4)
+	}
+	// Silence the start and the end, but only the end should be trimmed
+	for i := 0; i < 1000; i++ {
+		samples[i] *= 0.0001
+		samples[len(samples)-1-i] *= 0.0001
+	}
+	original := append([]float64(nil), samples...)
+	trimmed, end := TrimTail(sampleRate, -40, true, 0, samples)
+	kept := trimmed[0]
+
+	if kept[0] != original[0] {
+		t.Error("the start was trimmed")
+	}
+	last := len(original) - end - 1
+	if last != len(kept)-1 {
+		t.Fatalf("%d samples are kept, but %d were reported as trimmed", len(kept), end)
+	}
+	if !isZeroCrossing(original, last) && !isZeroCrossing(original, last+1) {
+		t.Fatalf("the last kept sample %d is not at a zero crossing", last)
+	}
+	if math.Abs(kept[last]) > math.Abs(original[last-1]) || math.Abs(kept[last]) > math.Abs(original[last+1]) {
+		t.Errorf("the last kept sample %d is not the one nearest to zero", last)
+	}
+}
@@ -0,0 +1,269 @@
+package kick
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	tuneIterations = 6   // how many times the tail pitch is detected while tuning
+	tuneAccuracy   = 1.0 // how close the detected pitch of the tail should be to the note, in cents
+)
+
+// noteNames are the names of the 12 pitch classes, starting at C
+var noteNames = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// pitchClasses maps note letters to pitch classes
+var pitchClasses = map[byte]int{'C': 0, 'D': 2, 'E': 4, 'F': 5, 'G': 7, 'A': 9, 'B': 11}
+
+// scaleModes are the intervals of the supported scales, in semitones above the root
+var scaleModes = map[string][]int{
+	"major":          {0, 2, 4, 5, 7, 9, 11},
+	"minor":          {0, 2, 3, 5, 7, 8, 10},
+	"harmonic minor": {0, 2, 3, 5, 7, 8, 11},
+	"melodic minor":  {0, 2, 3, 5, 7, 9, 11},
+	"dorian":         {0, 2, 3, 5, 7, 9, 10},
+	"phrygian":       {0, 1, 3, 5, 7, 8, 10},
+	"lydian":         {0, 2, 4, 6, 7, 9, 11},
+	"mixolydian":     {0, 2, 4, 5, 7, 9, 10},
+	"locrian":        {0, 1, 3, 5, 6, 8, 10},
+}
+
+// modeAliases are alternative names for the scales
+var modeAliases = map[string]string{
+	"":        "major",
+	"maj":     "major",
+	"ionian":  "major",
+	"m":       "minor",
+	"min":     "minor",
+	"aeolian": "minor",
+}
+
+// Key is a musical key, as a root pitch class and the intervals of a scale
+type Key struct {
+	Root      int   // pitch class of the root, from 0 (C) to 11 (B)
+	Intervals []int // semitones above the root that are in the key
+}
+
+// ParseNote parses a note name like "F1", "A#0" or "Bb2" into a MIDI note number, where A4 is 69 and C-1 is 0
+func ParseNote(name string) (int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("empty note name")
+	}
+	pitchClass, rest, err := parsePitchClass(name)
+	if err != nil {
+		return 0, err
+	}
+	octave, err := strconv.Atoi(rest)
+	if err != nil {
+		return 0, fmt.Errorf("invalid octave in note %q", name)
+	}
+	return (octave+1)*12 + pitchClass, nil
+}
+
+// parsePitchClass parses the letter and the optional sharp or flat at the start of the given string,
+// and returns the semitones above C together with the rest of the string. The result is not wrapped,
+// so that it runs from -1 for Cb to 12 for B#, and the octave of the note is carried along.
+func parsePitchClass(s string) (int, string, error) {
+	pitchClass, ok := pitchClasses[strings.ToUpper(s[:1])[0]]
+	if !ok {
+		return 0, "", fmt.Errorf("invalid note name %q", s)
+	}
+	s = s[1:]
+	if strings.HasPrefix(s, "#") {
+		pitchClass++
+		s = s[1:]
+	} else if strings.HasPrefix(s, "b") {
+		pitchClass--
+		s = s[1:]
+	}
+	return pitchClass, s, nil
+}
+
+// NoteName returns the name of the given MIDI note number, like "F1"
+func NoteName(note int) string {
+	octave := int(math.Floor(float64(note)/12)) - 1
+	return fmt.Sprintf("%s%d", noteNames[((note%12)+12)%12], octave)
+}
+
+// NoteFrequency returns the frequency of the given MIDI note number in 12-TET, for the given frequency of A4
+// and an offset in cents
+func NoteFrequency(note int, a4, cents float64) float64 {
+	return a4 * math.Pow(2, (float64(note)-69)/12+cents/1200)
+}
+
+// FrequencyToNote returns the nearest MIDI note number to the given frequency, and how many cents
+// the frequency is above (or below) that note
+func FrequencyToNote(freq, a4 float64) (int, float64) {
+	semitones := 12*math.Log2(freq/a4) + 69
+	note := int(math.Round(semitones))
+	return note, (semitones - float64(note)) * 100
+}
+
+// ParseKey parses a key like "F minor", "C# major", "Dm" or "Bb dorian"
+func ParseKey(name string) (Key, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Key{}, fmt.Errorf("empty key")
+	}
+	root, rest, err := parsePitchClass(name)
+	if err != nil {
+		return Key{}, err
+	}
+	mode := strings.ToLower(strings.TrimSpace(rest))
+	if alias, ok := modeAliases[mode]; ok {
+		mode = alias
+	}
+	intervals, ok := scaleModes[mode]
+	if !ok {
+		return Key{}, fmt.Errorf("unknown scale %q in key %q", rest, name)
+	}
+	return Key{Root: (root + 12) % 12, Intervals: intervals}, nil
+}
+
+// Contains returns true if the given MIDI note number is in the key
+func (k Key) Contains(note int) bool {
+	interval := ((note-k.Root)%12 + 12) % 12
+	for _, i := range k.Intervals {
+		if i == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// NearestNote returns the MIDI note number in the key that is nearest to the given frequency
+func (k Key) NearestNote(freq, a4 float64) int {
+	center, _ := FrequencyToNote(freq, a4)
+	best := center
+	bestDistance := math.Inf(1)
+	for note := center - 6; note <= center+6; note++ {
+		if !k.Contains(note) {
+			continue
+		}
+		distance := math.Abs(math.Log2(NoteFrequency(note, a4, 0) / freq))
+		if distance < bestDistance {
+			best, bestDistance = note, distance
+		}
+	}
+	return best
+}
+
+// a4 returns the configured frequency of A4, or 440 Hz if it is not set
+func (cfg *Settings) a4() float64 {
+	if cfg.A4 > 0 {
+		return cfg.A4
+	}
+	return 440
+}
+
+// SetNote tunes the tail of the kick to the given note, like "F1" or "A#0", using Tuning, A4 and TuneCents.
+// StartFreq is set StartInterval semitones above the tail note, or, if StartInterval is 0, kept at the same
+// ratio to EndFreq as before. Since the sweep does not end exactly at EndFreq, both are then corrected until
+// the detected pitch of the tail is at the note, and the frequency of the note is stored in NoteFreq.
+func (cfg *Settings) SetNote(name string) error {
+	note, err := ParseNote(name)
+	if err != nil {
+		return err
+	}
+	return cfg.tuneToNote(note)
+}
+
+// SetNoteNumber tunes the tail of the kick to the given MIDI note number, like SetNote
+func (cfg *Settings) SetNoteNumber(note int) error {
+	return cfg.tuneToNote(note)
+}
+
+// SetKey tunes the tail of the kick to the note in the given key, like "F minor", that is nearest to the current tail pitch.
+// The chosen MIDI note number is returned.
+func (cfg *Settings) SetKey(name string) (int, error) {
+	key, err := ParseKey(name)
+	if err != nil {
+		return 0, err
+	}
+	freq := cfg.tailPitch()
+	if freq <= 0 {
+		freq = cfg.EndFreq
+	}
+	note := key.NearestNote(freq, cfg.a4())
+	if err := cfg.tuneToNote(note); err != nil {
+		return 0, err
+	}
+	return note, nil
+}
+
+// noteFrequency returns the frequency of the given MIDI note number, using Tuning if it is set,
+// and equal temperament otherwise. TuneCents is applied on top of either.
+func (cfg *Settings) noteFrequency(note int) (float64, error) {
+	if cfg.Tuning == nil {
+		return NoteFrequency(note, cfg.a4(), cfg.TuneCents), nil
+	}
+	freq, err := cfg.Tuning.Frequency(note, cfg.a4())
+	if err != nil {
+		return 0, err
+	}
+	return freq * math.Pow(2, cfg.TuneCents/1200), nil
+}
+
+// tuneToNote tunes the tail to the given MIDI note number, by setting EndFreq, and StartFreq along with it,
+// and then correcting both with the detected pitch of the tail
+func (cfg *Settings) tuneToNote(note int) error {
+	freq, err := cfg.noteFrequency(note)
+	if err != nil {
+		return err
+	}
+	if cfg.StartInterval != 0 {
+		cfg.StartFreq = freq * math.Pow(2, cfg.StartInterval/12)
+	} else if cfg.EndFreq > 0 {
+		cfg.StartFreq *= freq / cfg.EndFreq
+	}
+	cfg.EndFreq = freq
+	cfg.NoteFreq = freq
+
+	// Scaling both frequencies moves the pitch of the tail by about the same ratio, so the scale is found with
+	// the secant method, in cents, starting with a step of the error itself
+	startFreq, endFreq := cfg.StartFreq, cfg.EndFreq
+	var lastShift, lastError float64
+	shift, bestShift, bestError := 0.0, 0.0, math.Inf(1)
+	for i := 0; i < tuneIterations; i++ {
+		cfg.StartFreq = startFreq * math.Pow(2, shift/1200)
+		cfg.EndFreq = endFreq * math.Pow(2, shift/1200)
+		detected := cfg.tailPitch()
+		if detected <= 0 {
+			break
+		}
+		tuneError := 1200 * math.Log2(detected/freq)
+		if math.Abs(tuneError) < math.Abs(bestError) {
+			bestShift, bestError = shift, tuneError
+		}
+		if math.Abs(tuneError) < tuneAccuracy {
+			break
+		}
+		next := shift - tuneError
+		if i > 0 && tuneError != lastError {
+			next = shift - tuneError*(shift-lastShift)/(tuneError-lastError)
+		}
+		lastShift, lastError, shift = shift, tuneError, next
+	}
+	cfg.StartFreq = startFreq * math.Pow(2, bestShift/1200)
+	cfg.EndFreq = endFreq * math.Pow(2, bestShift/1200)
+	return nil
+}
+
+// tailPitch renders a copy of cfg through the effects chain and returns the detected pitch of the tail,
+// or 0 if there is no pitch, like for the noise waveforms
+func (cfg *Settings) tailPitch() float64 {
+	if cfg.WaveformType > WaveSquare || cfg.SampleRate <= 0 || cfg.Duration <= 0 {
+		return 0
+	}
+	c := CopySettings(cfg)
+	c.Output = nil
+	channels, err := c.renderChannels()
+	if err != nil {
+		return 0
+	}
+	return DetectPitch(c.SampleRate, channels...).Frequency
+}
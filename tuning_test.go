@@ -0,0 +1,69 @@
+package kick
+
+import (
+	"io"
+	"math"
+	"testing"
+)
+
+func TestParseNote(t *testing.T) {
+	tests := []struct {
+		name    string
+		note    int
+		wantErr bool
+	}{
+		{"C-1", 0, false},
+		{"A4", 69, false},
+		{"F1", 29, false},
+		{"a#0", 22, false},
+		{"Bb2", 46, false},
+		{"Cb1", 23, false},
+		{"B#0", 24, false},
+		{"E#1", 29, false},
+		{"Fb1", 28, false},
+		{"", 0, true},
+		{"H2", 0, true},
+		{"C", 0, true},
+		{"C#x", 0, true},
+	}
+	for _, test := range tests {
+		note, err := ParseNote(test.name)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseNote(%q) = %d, want an error", test.name, note)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseNote(%q) returned an error: %v", test.name, err)
+		} else if note != test.note {
+			t.Errorf("ParseNote(%q) = %d, want %d", test.name, note, test.note)
+		}
+	}
+}
+
+// The detected pitch of the tail of a tuned kick should be at the note
+func TestSetNoteTailPitch(t *testing.T) {
+	presets := map[string]func(int, float64, int, io.WriteSeeker) (*Settings, error){
+		"deephouse": NewDeepHouse,
+		"808":       New808,
+		"909":       New909,
+	}
+	for name, preset := range presets {
+		cfg, err := preset(48000, 1, 16, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.SetNote("F1"); err != nil {
+			t.Fatal(err)
+		}
+		buffer, err := cfg.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pitch := DetectPitch(buffer.SampleRate, buffer.Channels...)
+		if cents := 1200 * math.Log2(pitch.Frequency/cfg.NoteFreq); math.Abs(cents) > 10 {
+			t.Errorf("the tail of the %s kick tuned to F1 is at %.2f Hz, %+.0f cents off %.2f Hz", name, pitch.Frequency, cents, cfg.NoteFreq)
+		}
+	}
+}
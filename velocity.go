@@ -0,0 +1,47 @@
+package kick
+
+import "math"
+
+// velocity returns the velocity from 0 to 1. Velocities from 0 to 1 are used as they are, so 1 is full velocity,
+// and velocities above 1 are MIDI velocities up to 127. 0 or below is the softest hit, which is silent when
+// VelocityAmp is 1. A Velocity of NaN means that it is not set, and gives full velocity.
+func (cfg *Settings) velocity() float64 {
+	switch {
+	case math.IsNaN(cfg.Velocity):
+		return 1
+	case cfg.Velocity <= 0:
+		return 0
+	case cfg.Velocity > 1:
+		return math.Min(cfg.Velocity/127, 1)
+	}
+	return cfg.Velocity
+}
+
+// velocityScale returns a factor from 1 at full velocity down to 1 - sensitivity at velocity 0
+func (cfg *Settings) velocityScale(sensitivity float64) float64 {
+	return 1 - sensitivity*(1-cfg.velocity())
+}
+
+// velocityGain returns the output gain for the velocity, following a square law
+func (cfg *Settings) velocityGain() float64 {
+	v := cfg.velocity()
+	return math.Max(0, 1-cfg.VelocityAmp*(1-v*v))
+}
+
+// startFreq returns the start frequency, with the depth of the pitch envelope scaled by the velocity
+func (cfg *Settings) startFreq() float64 {
+	if cfg.EndFreq <= 0 || cfg.StartFreq <= 0 {
+		return cfg.StartFreq
+	}
+	return cfg.EndFreq * math.Pow(cfg.StartFreq/cfg.EndFreq, cfg.velocityScale(cfg.VelocityPitch))
+}
+
+// drive returns the drive, scaled by the velocity
+func (cfg *Settings) drive() float64 {
+	return cfg.Drive * cfg.velocityScale(cfg.VelocityDrive)
+}
+
+// cutoff returns the cutoff of the tone filter, lowered by up to VelocityCutoff octaves for soft hits
+func (cfg *Settings) cutoff() float64 {
+	return cfg.FilterCutoff * math.Pow(2, -cfg.VelocityCutoff*(1-cfg.velocity()))
+}
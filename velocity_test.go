@@ -0,0 +1,27 @@
+package kick
+
+import (
+	"math"
+	"testing"
+)
+
+func TestVelocity(t *testing.T) {
+	tests := []struct {
+		velocity, want float64
+	}{
+		{math.NaN(), 1},
+		{0, 0},
+		{-1, 0},
+		{0.5, 0.5},
+		{1, 1},
+		{63.5, 0.5},
+		{127, 1},
+		{200, 1},
+	}
+	for _, test := range tests {
+		cfg := &Settings{Velocity: test.velocity}
+		if got := cfg.velocity(); got != test.want {
+			t.Errorf("velocity() with Velocity %v = %v, want %v", test.velocity, got, test.want)
+		}
+	}
+}
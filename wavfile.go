@@ -0,0 +1,107 @@
+package kick
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+)
+
+// ReadWAV decodes a PCM WAV file into one slice of samples per channel, in the -1 to 1 range
+func ReadWAV(r io.ReadSeeker) (channels [][]float64, sampleRate, bitDepth int, err error) {
+	decoder := wav.NewDecoder(r)
+	if !decoder.IsValidFile() {
+		return nil, 0, 0, errors.New("invalid WAV file")
+	}
+	if decoder.WavAudioFormat != 1 {
+		return nil, 0, 0, fmt.Errorf("unsupported WAV audio format: %d (only PCM is supported)", decoder.WavAudioFormat)
+	}
+	buf, err := decoder.FullPCMBuffer()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	numChannels := buf.Format.NumChannels
+	sampleRate = buf.Format.SampleRate
+	bitDepth = int(decoder.BitDepth)
+	fullScale := float64(int(1) << (bitDepth - 1))
+
+	numFrames := len(buf.Data) / numChannels
+	channels = make([][]float64, numChannels)
+	for c := range channels {
+		channels[c] = make([]float64, numFrames)
+		for i := 0; i < numFrames; i++ {
+			sample := buf.Data[i*numChannels+c]
+			if bitDepth == 8 {
+				// 8-bit WAV samples are unsigned
+				sample -= 128
+			}
+			channels[c][i] = float64(sample) / fullScale
+		}
+	}
+	return channels, sampleRate, bitDepth, nil
+}
+
+// WriteWAV encodes the given channels, with samples in the -1 to 1 range, as a PCM WAV file
+func WriteWAV(w io.WriteSeeker, channels [][]float64, sampleRate, bitDepth int) error {
+	if len(channels) == 0 {
+		return errors.New("no channels to write")
+	}
+	numChannels := len(channels)
+	numFrames := len(channels[0])
+	fullScale := float64(int(1) << (bitDepth - 1))
+
+	data := make([]int, numFrames*numChannels)
+	for c, ch := range channels {
+		for i := 0; i < numFrames && i < len(ch); i++ {
+			sample := math.Max(-fullScale, math.Min(fullScale-1, math.Round(ch[i]*fullScale)))
+			if bitDepth == 8 {
+				sample += 128
+			}
+			data[i*numChannels+c] = int(sample)
+		}
+	}
+
+	buffer := &audio.IntBuffer{
+		Data:           data,
+		Format:         &audio.Format{SampleRate: sampleRate, NumChannels: numChannels},
+		SourceBitDepth: bitDepth,
+	}
+
+	encoder := wav.NewEncoder(w, sampleRate, bitDepth, numChannels, 1)
+	if err := encoder.Write(buffer); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// ProcessWAVFile reads a WAV file, lets the given function process the channels and writes the returned channels
+// to the output path, using the same sample rate and bit depth. The input and output path may be the same.
+func ProcessWAVFile(inputPath, outputPath string, process func(channels [][]float64, sampleRate int) ([][]float64, error)) error {
+	inFile, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	channels, sampleRate, bitDepth, err := ReadWAV(inFile)
+	inFile.Close()
+	if err != nil {
+		return fmt.Errorf("Error reading %s: %v", inputPath, err)
+	}
+
+	channels, err = process(channels, sampleRate)
+	if err != nil {
+		return err
+	}
+
+	outFile, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	return WriteWAV(outFile, channels, sampleRate, bitDepth)
+}